@@ -1,17 +1,20 @@
 package dbtools
 
 import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/Kaguya154/dbhelper/types"
 )
 
+// CondCache 缓存的 SQL 模板，参数不随模板缓存，命中后由解析器按当前条件重新收集
 type CondCache struct {
 	SQL      string
-	Args     []interface{}
 	expireAt int64 // 过期时间戳（秒）
 	freq     int32 // 访问频率
 }
@@ -32,33 +35,307 @@ func init() {
 	}()
 }
 
-func SetCondCache(driver uint8, op types.OpType, expr *types.ConditionExpr, sql string, args []interface{}) {
-	key := MakeCondCacheFastKey(driver, op, expr)
+func SetCondCache(key string, sql string) {
 	now := time.Now().Unix()
 	val := &CondCache{
 		SQL:      sql,
-		Args:     args,
 		expireAt: now + ttlSeconds,
 		freq:     1,
 	}
 	globalCondCache.Store(key, val)
 }
 
-func GetCondCache(driver uint8, op types.OpType, expr *types.ConditionExpr) (string, []interface{}, bool) {
-	key := MakeCondCacheFastKey(driver, op, expr)
+func GetCondCache(key string) (string, bool) {
 	val, ok := globalCondCache.Load(key)
 	if !ok {
-		return "", nil, false
+		return "", false
 	}
 	atomic.AddInt32(&val.(*CondCache).freq, 1)
-	return val.(*CondCache).SQL, val.(*CondCache).Args, true
+	return val.(*CondCache).SQL, true
 }
 
-func MakeCondCacheFastKey(driver uint8, op types.OpType, expr *types.ConditionExpr) uintptr {
-	h := uintptr(unsafe.Pointer(expr)) // expr 指针
-	h ^= uintptr(op) << 56
-	h ^= uintptr(driver) << 48
-	return h
+// MakeCondCacheKey 根据条件树的结构生成缓存键。
+// 键只包含影响 SQL 文本的信息（操作、字段、树形、值的个数与空值），不包含参数值，
+// 因此结构相同、值不同的条件会共享同一个 SQL 模板。
+func MakeCondCacheKey(driver uint8, op types.OpType, where, set *types.ConditionExpr) string {
+	var sb strings.Builder
+	sb.Grow(64)
+	writeKeyHeader(&sb, driver, op)
+	writeExprKey(&sb, where, false)
+	sb.WriteByte('/')
+	writeExprKey(&sb, set, false)
+	return sb.String()
+}
+
+// MakeReturningCacheKey 生成带 RETURNING 子句的缓存键
+func MakeReturningCacheKey(driver uint8, op types.OpType, where, set *types.ConditionExpr, returning []string) string {
+	var sb strings.Builder
+	sb.Grow(64)
+	writeKeyHeader(&sb, driver, op)
+	writeExprKey(&sb, where, false)
+	sb.WriteByte('/')
+	writeExprKey(&sb, set, false)
+	sb.WriteString(" R")
+	for _, col := range returning {
+		writeStringKey(&sb, col)
+	}
+	return sb.String()
+}
+
+// MakeCondValueCacheKey 与 MakeCondCacheKey 相同，但同时包含参数值，
+// 用于值会被直接写入结果的解析器（如 JsonParser）。
+func MakeCondValueCacheKey(driver uint8, op types.OpType, where, set *types.ConditionExpr) string {
+	var sb strings.Builder
+	sb.WriteByte('v')
+	writeKeyHeader(&sb, driver, op)
+	writeExprKey(&sb, where, true)
+	sb.WriteByte('/')
+	writeExprKey(&sb, set, true)
+	return sb.String()
+}
+
+// MakeQueryCacheKey 生成带查询选项的 SELECT 缓存键，LIMIT/OFFSET 以参数绑定，只记录是否存在
+func MakeQueryCacheKey(driver uint8, where *types.ConditionExpr, opts *types.QueryOptions) string {
+	var sb strings.Builder
+	sb.Grow(64)
+	writeKeyHeader(&sb, driver, types.OpQuery)
+	writeExprKey(&sb, where, false)
+	sb.WriteByte('/')
+	writeQueryOptionsKey(&sb, opts, false)
+	return sb.String()
+}
+
+// MakeQueryValueCacheKey 与 MakeQueryCacheKey 相同，但同时包含参数值
+func MakeQueryValueCacheKey(driver uint8, where *types.ConditionExpr, opts *types.QueryOptions) string {
+	var sb strings.Builder
+	sb.WriteByte('v')
+	writeKeyHeader(&sb, driver, types.OpQuery)
+	writeExprKey(&sb, where, true)
+	sb.WriteByte('/')
+	writeQueryOptionsKey(&sb, opts, true)
+	return sb.String()
+}
+
+// MakeInsertManyCacheKey 生成多行 INSERT 的缓存键，由第一行的结构与行数决定
+func MakeInsertManyCacheKey(driver uint8, rows []*types.ConditionExpr) string {
+	var sb strings.Builder
+	sb.Grow(64)
+	writeKeyHeader(&sb, driver, types.OpInsert)
+	sb.WriteByte('*')
+	sb.WriteString(strconv.Itoa(len(rows)))
+	if len(rows) > 0 {
+		writeExprKey(&sb, rows[0], false)
+	}
+	return sb.String()
+}
+
+// MakeUpsertCacheKey 生成 UPSERT 的缓存键
+func MakeUpsertCacheKey(driver uint8, data *types.ConditionExpr, opts *types.UpsertOptions) string {
+	var sb strings.Builder
+	sb.Grow(64)
+	writeKeyHeader(&sb, driver, types.OpInsert)
+	sb.WriteByte('U')
+	writeExprKey(&sb, data, false)
+	if opts != nil {
+		if opts.DoNothing {
+			sb.WriteString(" N")
+		}
+		sb.WriteString(" C")
+		for _, col := range opts.ConflictColumns {
+			writeStringKey(&sb, col)
+		}
+		sb.WriteString(" S")
+		for _, col := range opts.UpdateColumns {
+			writeStringKey(&sb, col)
+		}
+	}
+	return sb.String()
+}
+
+func writeKeyHeader(sb *strings.Builder, driver uint8, op types.OpType) {
+	sb.WriteString(strconv.Itoa(int(driver)))
+	sb.WriteByte(':')
+	sb.WriteString(strconv.Itoa(int(op)))
+	sb.WriteByte(':')
+}
+
+// writeExprKey 递归写入条件树的结构指纹
+func writeExprKey(sb *strings.Builder, expr *types.ConditionExpr, withValues bool) {
+	if expr == nil {
+		sb.WriteByte('~')
+		return
+	}
+	sb.WriteByte('(')
+	sb.WriteString(string(expr.Op))
+	if expr.Field != "" {
+		writeStringKey(sb, expr.Field)
+	}
+	switch {
+	case expr.Op != types.OpRaw && types.IsNilValue(expr.Value):
+		// 带类型的空指针同样会被改写为 IS NULL
+		sb.WriteString(" n")
+	case writeSQLValueKey(sb, expr.Value, withValues):
+	case expr.Op == types.OpRaw || withValues:
+		// 原始条件的内容本身就是 SQL 文本
+		writeValueKey(sb, expr.Value)
+	}
+	if expr.Values != nil {
+		sb.WriteString(" #")
+		sb.WriteString(strconv.Itoa(len(expr.Values)))
+		for _, v := range expr.Values {
+			if !writeSQLValueKey(sb, v, withValues) && withValues {
+				writeValueKey(sb, v)
+			}
+		}
+	}
+	for _, e := range expr.Exprs {
+		sb.WriteByte(' ')
+		writeExprKey(sb, e, withValues)
+	}
+	sb.WriteByte(')')
+}
+
+// writeSQLValueKey 写入直接渲染为 SQL 的值（列引用、表达式、子查询）的指纹，
+// 普通值返回 false 由调用方决定是否写入
+func writeSQLValueKey(sb *strings.Builder, v interface{}, withValues bool) bool {
+	switch v := v.(type) {
+	case sql.NamedArg:
+		// 命名参数的值同样可能是列引用或表达式
+		sb.WriteString(" @")
+		writeStringKey(sb, v.Name)
+		writeOperandKey(sb, v.Value, withValues)
+	case types.Column:
+		sb.WriteString(" c")
+		writeStringKey(sb, string(v))
+	case *types.SubQuery:
+		writeSubQueryKey(sb, v, withValues)
+	case *types.ArithExpr:
+		sb.WriteString(" (a")
+		writeStringKey(sb, v.Op)
+		writeOperandKey(sb, v.Left, withValues)
+		writeOperandKey(sb, v.Right, withValues)
+		sb.WriteByte(')')
+	case *types.FuncExpr:
+		sb.WriteString(" (f")
+		writeStringKey(sb, v.Name)
+		sb.WriteString(strconv.Itoa(len(v.Args)))
+		for _, arg := range v.Args {
+			writeOperandKey(sb, arg, withValues)
+		}
+		sb.WriteByte(')')
+	default:
+		return false
+	}
+	return true
+}
+
+// writeOperandKey 写入表达式操作数，普通值只记录为绑定参数（及是否为空）
+func writeOperandKey(sb *strings.Builder, v interface{}, withValues bool) {
+	switch {
+	case writeSQLValueKey(sb, v, withValues):
+	case withValues:
+		writeValueKey(sb, v)
+	default:
+		sb.WriteString(" ?")
+	}
+}
+
+// writeSubQueryKey 写入子查询的结构指纹
+func writeSubQueryKey(sb *strings.Builder, q *types.SubQuery, withValues bool) {
+	if q == nil {
+		sb.WriteString(" s~")
+		return
+	}
+	sb.WriteString(" s")
+	writeStringKey(sb, q.Table)
+	sb.WriteByte(' ')
+	writeExprKey(sb, q.Where, withValues)
+	sb.WriteByte(' ')
+	writeQueryOptionsKey(sb, q.Options, withValues)
+}
+
+// writeQueryOptionsKey 写入查询选项的结构指纹
+func writeQueryOptionsKey(sb *strings.Builder, opts *types.QueryOptions, withValues bool) {
+	if opts == nil {
+		sb.WriteByte('~')
+		return
+	}
+	sb.WriteString("(Q")
+	if opts.Alias != "" {
+		sb.WriteString(" T")
+		writeStringKey(sb, opts.Alias)
+	}
+	for _, j := range opts.Joins {
+		sb.WriteString(" J")
+		writeStringKey(sb, string(j.Type))
+		writeStringKey(sb, j.Table)
+		writeStringKey(sb, j.Alias)
+		writeExprKey(sb, j.On, withValues)
+	}
+	if opts.Distinct {
+		sb.WriteString(" D")
+	}
+	for _, col := range opts.Columns {
+		writeStringKey(sb, col)
+	}
+	for _, a := range opts.Aggregates {
+		sb.WriteString(" A")
+		if a.Distinct {
+			sb.WriteByte('D')
+		}
+		writeStringKey(sb, string(a.Func))
+		writeStringKey(sb, a.Field)
+		writeStringKey(sb, a.Alias)
+	}
+	for _, col := range opts.GroupBy {
+		sb.WriteString(" G")
+		writeStringKey(sb, col)
+	}
+	if opts.Having != nil {
+		sb.WriteString(" H")
+		writeExprKey(sb, opts.Having, withValues)
+	}
+	for _, o := range opts.OrderBy {
+		sb.WriteString(" O")
+		if o.Desc {
+			sb.WriteByte('-')
+		}
+		writeStringKey(sb, o.Field)
+	}
+	if opts.Limit > 0 {
+		sb.WriteString(" L")
+		if withValues {
+			sb.WriteString(strconv.Itoa(opts.Limit))
+		}
+	}
+	if opts.Offset > 0 {
+		sb.WriteString(" F")
+		if withValues {
+			sb.WriteString(strconv.Itoa(opts.Offset))
+		}
+	}
+	if opts.Lock != "" || opts.LockWait != "" {
+		sb.WriteString(" K")
+		writeStringKey(sb, string(opts.Lock))
+		writeStringKey(sb, string(opts.LockWait))
+	}
+	sb.WriteByte(')')
+}
+
+func writeValueKey(sb *strings.Builder, v interface{}) {
+	if s, ok := v.(string); ok {
+		writeStringKey(sb, s)
+		return
+	}
+	writeStringKey(sb, fmt.Sprintf("%T:%v", v, v))
+}
+
+// writeStringKey 以长度前缀写入字符串，避免字段内容与分隔符混淆
+func writeStringKey(sb *strings.Builder, s string) {
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Itoa(len(s)))
+	sb.WriteByte(':')
+	sb.WriteString(s)
 }
 
 // 后台定时清理过期和低频缓存
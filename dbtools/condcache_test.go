@@ -1,6 +1,8 @@
 package dbtools_test
 
 import (
+	"database/sql"
+	"reflect"
 	"testing"
 
 	"github.com/Kaguya154/dbhelper"
@@ -41,12 +43,11 @@ func TestSqliteCondCache(t *testing.T) {
 	t.Logf("生成的查询SQL: %s", querySQL)
 	t.Logf("生成的查询Args: %v", quaryArgs)
 	// 测试cache
-	cache, argCache, b := dbtools.GetCondCache(sqlite.DriverID, types.OpQuery, queryCond)
-	if !b {
+	cache, b := dbtools.GetCondCache(dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, queryCond, nil))
+	if !b || cache != querySQL {
 		t.Fatalf("查询条件缓存未命中")
 	}
 	t.Logf("查询条件缓存命中: %s", cache)
-	t.Logf("查询条件缓存Args: %v", argCache)
 
 	// 测试插入条件
 	insertCond := dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).
@@ -58,12 +59,11 @@ func TestSqliteCondCache(t *testing.T) {
 	t.Logf("生成的插入SQL: %s", insertSQL)
 	t.Logf("生成的插入Args: %v", insertArgs)
 	// 测试cache
-	cache, argCache, b = dbtools.GetCondCache(sqlite.DriverID, types.OpInsert, insertCond)
-	if !b {
+	cache, b = dbtools.GetCondCache(dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpInsert, insertCond, nil))
+	if !b || cache != insertSQL {
 		t.Fatalf("插入条件缓存未命中")
 	}
 	t.Logf("插入条件缓存命中: %s", cache)
-	t.Logf("插入条件缓存Args: %v", argCache)
 
 	// 测试复杂条件
 	complexSQL, complexArgs, err := p.ParseAndCache(types.OpUpdate, complexCondition, set)
@@ -73,15 +73,131 @@ func TestSqliteCondCache(t *testing.T) {
 	t.Logf("生成的复杂SQL: %s", complexSQL)
 	t.Logf("生成的复杂Args: %v", complexArgs)
 	// 测试cache
-	cache, argCache, b = dbtools.GetCondCache(sqlite.DriverID, types.OpUpdate, complexCondition)
-	if !b {
+	cache, b = dbtools.GetCondCache(dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpUpdate, complexCondition, set))
+	if !b || cache != complexSQL {
 		t.Fatalf("复杂条件缓存未命中")
 	}
 	t.Logf("复杂条件缓存命中: %s", cache)
-	t.Logf("复杂条件缓存Args: %v", argCache)
 
 }
 
+func TestCondCacheKey(t *testing.T) {
+	// 分别构建的相同结构条件共享同一个键
+	a := dbhelper.Cond().Eq("name", "Tom").In("role", []interface{}{"admin", "user"}).Build()
+	b := dbhelper.Cond().Eq("name", "Jerry").In("role", []interface{}{"guest", "user"}).Build()
+	if dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, a, nil) != dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, b, nil) {
+		t.Fatalf("相同结构的条件应生成相同的键")
+	}
+
+	// IN 值个数不同，SQL 不同
+	c := dbhelper.Cond().Eq("name", "Tom").In("role", []interface{}{"admin"}).Build()
+	if dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, a, nil) == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, c, nil) {
+		t.Fatalf("IN 值个数不同的条件不应共享键")
+	}
+
+	// 字段、驱动、操作不同
+	d := dbhelper.Cond().Eq("email", "Tom").In("role", []interface{}{"admin", "user"}).Build()
+	keyA := dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, a, nil)
+	for _, key := range []string{
+		dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, d, nil),
+		dbtools.MakeCondCacheKey(sqlite.DriverID+1, types.OpQuery, a, nil),
+		dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpDelete, a, nil),
+	} {
+		if key == keyA {
+			t.Fatalf("不同的字段/驱动/操作不应共享键: %s", key)
+		}
+	}
+
+	// Update 的 SET 结构参与键计算
+	set1 := dbhelper.Cond().Eq("age", 20).Build()
+	set2 := dbhelper.Cond().Eq("status", "active").Build()
+	if dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpUpdate, a, set1) == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpUpdate, a, set2) {
+		t.Fatalf("SET 不同的更新不应共享键")
+	}
+
+	// 原始条件的文本参与键计算
+	r1 := dbhelper.Cond().Raw("age > 1").Build()
+	r2 := dbhelper.Cond().Raw("age > 2").Build()
+	if dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, r1, nil) == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, r2, nil) {
+		t.Fatalf("不同的原始条件不应共享键")
+	}
+
+	// 列引用直接写入 SQL，引用的列不同时不应共享键
+	c1 := dbhelper.Cond().Eq("o.user_id", types.Col("u.id")).Build()
+	c2 := dbhelper.Cond().Eq("o.user_id", types.Col("u.parent_id")).Build()
+	c3 := dbhelper.Cond().Eq("o.user_id", "u.id").Build()
+	k1 := dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, c1, nil)
+	if k1 == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, c2, nil) || k1 == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, c3, nil) {
+		t.Fatalf("列引用不同的条件不应共享键")
+	}
+
+	// 子查询的表与结构参与键计算
+	s1 := dbhelper.Cond().In("id", types.Sub("banned", nil)).Build()
+	s2 := dbhelper.Cond().In("id", types.Sub("vip", nil)).Build()
+	if dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, s1, nil) == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpQuery, s2, nil) {
+		t.Fatalf("子查询不同的条件不应共享键")
+	}
+
+	// 表达式的结构参与键计算，操作数中的普通值不参与
+	e1 := dbhelper.Cond().Eq("counter", types.Col("counter").Add(1)).Build()
+	e2 := dbhelper.Cond().Eq("counter", types.Col("counter").Add(5)).Build()
+	e3 := dbhelper.Cond().Eq("counter", types.Col("counter").Sub(1)).Build()
+	k1 = dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpUpdate, nil, e1)
+	if k1 != dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpUpdate, nil, e2) {
+		t.Fatalf("只有操作数值不同的表达式应共享键")
+	}
+	if k1 == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpUpdate, nil, e3) {
+		t.Fatalf("运算符不同的表达式不应共享键")
+	}
+
+	// 命名参数的值为列引用时参与键计算
+	n1 := dbhelper.Cond().Raw("SELECT :a FROM t", sql.Named("a", 1)).Build()
+	n2 := dbhelper.Cond().Raw("SELECT :a FROM t", sql.Named("a", 2)).Build()
+	n3 := dbhelper.Cond().Raw("SELECT :a FROM t", sql.Named("a", types.Col("x"))).Build()
+	k1 = dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpExec, n1, nil)
+	if k1 != dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpExec, n2, nil) {
+		t.Fatalf("只有命名参数值不同的原始条件应共享键")
+	}
+	if k1 == dbtools.MakeCondCacheKey(sqlite.DriverID, types.OpExec, n3, nil) {
+		t.Fatalf("命名参数为列引用的原始条件不应与普通值共享键")
+	}
+	driver, err := dbhelper.GetDriver(sqlite.DriverName)
+	if err != nil {
+		t.Fatalf("获取驱动失败: %v", err)
+	}
+	if _, _, err = driver.Parser().ParseAndCache(types.OpExec, n1, nil); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	sqlStr, args, err := driver.Parser().ParseAndCache(types.OpExec, n3, nil)
+	if err != nil || sqlStr != "SELECT `x` FROM t" || len(args) != 0 {
+		t.Fatalf("命名参数列引用不应命中普通值的缓存: %s %v %v", sqlStr, args, err)
+	}
+}
+
+func TestCondCacheArgs(t *testing.T) {
+	driver, err := dbhelper.GetDriver(sqlite.DriverName)
+	if err != nil {
+		t.Fatalf("获取驱动失败: %v", err)
+	}
+	p := driver.Parser()
+
+	// 命中缓存时参数必须来自当前条件
+	for _, name := range []string{"Tom", "Jerry", "Alice"} {
+		where := dbhelper.Cond().Eq("name", name).Gt("age", len(name)).Build()
+		upd := dbhelper.Cond().Eq("nick", name+"!").Build()
+		sqlStr, args, err := p.ParseAndCache(types.OpUpdate, where, upd)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+		if sqlStr != "UPDATE %s SET `nick`=? WHERE (`name` = ?) AND (`age` > ?)" {
+			t.Fatalf("SQL错误: %s", sqlStr)
+		}
+		if !reflect.DeepEqual(args, []interface{}{name + "!", name, len(name)}) {
+			t.Fatalf("参数错误: %v", args)
+		}
+	}
+}
+
 var condition = dbhelper.Cond().Or(dbhelper.Cond().Eq("id", 123).Eq("name", "test")).Build()
 var complexCondition = dbhelper.Cond().Or(
 	dbhelper.Cond().And(
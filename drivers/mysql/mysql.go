@@ -1,45 +1,56 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
 	"github.com/Kaguya154/dbhelper/parser"
 	"github.com/Kaguya154/dbhelper/types"
 
-	_ "github.com/go-sql-driver/mysql"
+	mysqldrv "github.com/go-sql-driver/mysql"
 )
 
 const DriverName = "mysql"
 const DriverID uint8 = 1
 
 func GetDriver() *MySQLDriver {
-	return &MySQLDriver{
-		parser: &parser.SQLParser{
-			DriverName: DriverName,
-			DriverID:   DriverID,
-			QuoteFunc:  func(identifier string) string { return "`" + identifier + "`" },
-		},
+	d := &MySQLDriver{}
+	d.parser = &parser.SQLParser{
+		DriverName:      DriverName,
+		DriverID:        DriverID,
+		QuoteFunc:       func(identifier string) string { return "`" + identifier + "`" },
+		PlaceholderFunc: d.Placeholder,
+		Dialect:         parser.DialectMySQL,
 	}
+	d.dialect = &sqlbase.Dialect{
+		Parser:    d.parser,
+		Quote:     d.Quote,
+		MaxParams: maxParams,
+		// 不提供 BatchInsertIDs：多行插入的 ID 只有在 innodb_autoinc_lock_mode 为 0 或 1
+		// 且 auto_increment_increment 为 1 时才连续，MySQL 8 默认的模式 2 不保证，因此 InsertMany 不返回 ID
+		Returning:     returningRows,
+		ClassifyError: d.ClassifyError,
+		Schema:        schema{},
+	}
+	return d
 }
 
 // MySQLDriver 实现 dbhelper.Driver
 type MySQLDriver struct {
-	parser types.DSLParser
+	parser  types.DSLParser
+	dialect *sqlbase.Dialect
 }
 
 func (d *MySQLDriver) Open(cfg types.DBConfig) (types.Conn, error) {
-	conn, err := sql.Open(DriverName, cfg.DSN)
+	conn, err := sqlbase.Open(DriverName, cfg, d.dialect)
 	if err != nil {
 		return nil, err
 	}
-	if cfg.MaxOpen > 0 {
-		conn.SetMaxOpenConns(cfg.MaxOpen)
-	}
-	if cfg.MaxIdle > 0 {
-		conn.SetMaxIdleConns(cfg.MaxIdle)
-	}
-	return &MySQLConn{conn: conn, driver: d}, nil
+	return conn, nil
 }
 
 func (d *MySQLDriver) Quote(identifier string) string {
@@ -47,203 +58,214 @@ func (d *MySQLDriver) Quote(identifier string) string {
 }
 
 func (d *MySQLDriver) Placeholder(n int) string {
-	return "?"
+	return parser.QuestionPlaceholder(n)
 }
 
 func (d *MySQLDriver) Parser() types.DSLParser {
 	return d.parser
 }
 
-// MySQLConn 实现 dbhelper.Conn
-type MySQLConn struct {
-	conn   *sql.DB
-	driver *MySQLDriver
+// IsRetryable 死锁（1213）与锁等待超时（1205）可以通过重试整个事务解决
+func (d *MySQLDriver) IsRetryable(err error) bool {
+	de := d.ClassifyError(err)
+	return de != nil && de.Kind == types.ErrDeadlock
 }
 
-func (db *MySQLConn) Begin() (types.Tx, error) {
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return nil, err
+// ClassifyError 按服务端错误号分类，约束名与表名从错误信息中解析
+func (d *MySQLDriver) ClassifyError(err error) *types.DBError {
+	if errors.Is(err, mysqldrv.ErrInvalidConn) {
+		return &types.DBError{Kind: types.ErrConnection}
 	}
-	return &MySQLTx{tx: tx, driver: db.driver}, nil
-}
-
-func (db *MySQLConn) Insert(table string, data *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpInsert, data, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
+	var me *mysqldrv.MySQLError
+	if !errors.As(err, &me) {
+		return nil
 	}
-	return res.LastInsertId()
-}
-
-func (db *MySQLConn) Query(table string, cond *types.ConditionExpr) (*types.Rows, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpQuery, cond, nil)
-	if err != nil {
-		return nil, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	rows, err := db.conn.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	result := []map[string]interface{}{}
-	for rows.Next() {
-		row := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range row {
-			rowPtrs[i] = &row[i]
+	switch me.Number {
+	case 1062, 1586:
+		// Duplicate entry 'x' for key 'users.email'，8.0 之前不带表名
+		de := &types.DBError{Kind: types.ErrUniqueViolation, Constraint: between(me.Message, "for key '", "'")}
+		if table, key, ok := strings.Cut(de.Constraint, "."); ok {
+			de.Table, de.Constraint = table, key
 		}
-		if err := rows.Scan(rowPtrs...); err != nil {
-			return nil, err
+		return de
+	case 1216, 1217, 1451, 1452:
+		// ... a foreign key constraint fails (`db`.`orders`, CONSTRAINT `fk_user` FOREIGN KEY ...)
+		return &types.DBError{
+			Kind:       types.ErrForeignKeyViolation,
+			Table:      between(me.Message, "`.`", "`"),
+			Constraint: between(me.Message, "CONSTRAINT `", "`"),
 		}
-		m := map[string]interface{}{}
-		for i, col := range columns {
-			m[col] = row[i]
-		}
-		result = append(result, m)
+	case 1048:
+		// Column 'name' cannot be null
+		return &types.DBError{Kind: types.ErrNotNullViolation, Constraint: between(me.Message, "Column '", "'")}
+	case 1364:
+		// Field 'name' doesn't have a default value
+		return &types.DBError{Kind: types.ErrNotNullViolation, Constraint: between(me.Message, "Field '", "'")}
+	case 1205, 1213:
+		return &types.DBError{Kind: types.ErrDeadlock}
+	case 1040, 1053:
+		return &types.DBError{Kind: types.ErrConnection}
 	}
-	return types.NewRows(result), nil
+	return nil
 }
 
-func (db *MySQLConn) Update(table string, where, set *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpUpdate, where, set)
-	if err != nil {
-		return 0, err
+// between 返回 s 中 start 与其后第一个 end 之间的内容，找不到时返回空串
+func between(s, start, end string) string {
+	_, rest, ok := strings.Cut(s, start)
+	if !ok {
+		return ""
 	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
+	v, _, ok := strings.Cut(rest, end)
+	if !ok {
+		return ""
 	}
-	return res.RowsAffected()
+	return v
 }
 
-func (db *MySQLConn) Delete(table string, cond *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpDelete, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
+// maxParams MySQL 预处理语句的参数上限
+const maxParams = 65535
 
-func (db *MySQLConn) Exec(cond *types.ConditionExpr) (int64, error) {
-	sqlStr, args, err := db.driver.Parser().ParseAndCache(types.OpExec, cond, nil)
-	if err != nil {
-		return 0, err
+// returningRows MySQL 不支持 RETURNING，通过主键回查模拟：
+// Insert 使用 LastInsertId（非自增主键使用插入数据中的值）回查；
+// Update 先锁定并记录命中行的主键，更新后按主键回查（更新主键本身时无法回查）；
+// Delete 先锁定并读取命中行再删除。
+// 在 Conn 上调用时会开启事务保证一致性，表必须有单列主键。
+func returningRows(ctx context.Context, q sqlbase.Querier, d *sqlbase.Dialect, op types.OpType, table string, where, set *types.ConditionExpr, cols []string) (*types.Rows, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("RETURNING columns cannot be empty")
 	}
-	res, err := db.conn.Exec(sqlStr, args...)
-	if err != nil {
-		return 0, err
+	if db, ok := q.(*sql.DB); ok {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := returningRows(ctx, tx, d, op, table, where, set, cols)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return rows, nil
 	}
-	return res.RowsAffected()
-}
 
-// MySQLTx 实现 dbhelper.Tx
-type MySQLTx struct {
-	tx     *sql.Tx
-	driver *MySQLDriver
-}
+	quoted := d.Quote(table)
+	opts := &types.QueryOptions{Columns: cols}
+	switch op {
+	case types.OpInsert:
+		pk, err := primaryKey(ctx, q, table)
+		if err != nil {
+			return nil, err
+		}
+		sqlTmpl, args, err := d.Parser.ParseAndCache(types.OpInsert, where, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := q.ExecContext(ctx, fmt.Sprintf(sqlTmpl, quoted), args...)
+		if err != nil {
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		var key interface{} = id
+		if id == 0 {
+			v, ok := fieldValue(where, pk)
+			if !ok {
+				return nil, fmt.Errorf("RETURNING emulation cannot determine primary key %s of inserted row", pk)
+			}
+			key = v
+		}
+		return selectRows(ctx, q, d, quoted, types.NewCondition().Eq(pk, key).Build(), opts)
 
-func (tx *MySQLTx) Query(table string, cond *types.ConditionExpr) (*types.Rows, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpQuery, cond, nil)
-	if err != nil {
-		return nil, err
-	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	rows, err := tx.tx.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	case types.OpUpdate:
+		pk, err := primaryKey(ctx, q, table)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := selectRows(ctx, q, d, quoted, where, &types.QueryOptions{Columns: []string{pk}, Lock: types.LockForUpdate})
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]interface{}, 0, locked.Count())
+		for locked.Next() {
+			keys = append(keys, locked.Get(pk))
+		}
+		if len(keys) == 0 {
+			return types.NewRows(nil), nil
+		}
+		sqlTmpl, args, err := d.Parser.ParseAndCache(types.OpUpdate, where, set)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = q.ExecContext(ctx, fmt.Sprintf(sqlTmpl, quoted), args...); err != nil {
+			return nil, err
+		}
+		return selectRows(ctx, q, d, quoted, types.NewCondition().In(pk, keys).Build(), opts)
 
-	columns, _ := rows.Columns()
-	result := []map[string]interface{}{}
-	for rows.Next() {
-		row := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range row {
-			rowPtrs[i] = &row[i]
+	case types.OpDelete:
+		rows, err := selectRows(ctx, q, d, quoted, where, &types.QueryOptions{Columns: cols, Lock: types.LockForUpdate})
+		if err != nil {
+			return nil, err
 		}
-		if err := rows.Scan(rowPtrs...); err != nil {
+		sqlTmpl, args, err := d.Parser.ParseAndCache(types.OpDelete, where, nil)
+		if err != nil {
 			return nil, err
 		}
-		m := map[string]interface{}{}
-		for i, col := range columns {
-			m[col] = row[i]
+		if _, err = q.ExecContext(ctx, fmt.Sprintf(sqlTmpl, quoted), args...); err != nil {
+			return nil, err
 		}
-		result = append(result, m)
+		return rows, nil
 	}
-	return types.NewRows(result), nil
+	return nil, fmt.Errorf("RETURNING only supports Insert, Update and Delete")
 }
 
-func (tx *MySQLTx) Insert(table string, data *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpInsert, data, nil)
+// selectRows 按查询选项读取全部结果，回查前通过 opts.Lock 锁定命中行
+func selectRows(ctx context.Context, q sqlbase.Querier, d *sqlbase.Dialect, quoted string, where *types.ConditionExpr, opts *types.QueryOptions) (*types.Rows, error) {
+	sqlTmpl, args, err := d.Parser.ParseQueryAndCache(where, opts)
 	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return res.LastInsertId()
-}
-
-func (tx *MySQLTx) Update(table string, where, set *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpUpdate, where, set)
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(sqlTmpl, quoted), args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
+	cur, err := types.NewCursor(rows)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return res.RowsAffected()
+	return cur.Collect()
 }
 
-func (tx *MySQLTx) Delete(table string, cond *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpDelete, cond, nil)
+// primaryKey 查询表的单列主键
+func primaryKey(ctx context.Context, q sqlbase.Querier, table string) (string, error) {
+	cols, err := schema{}.PrimaryKey(ctx, q, table)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
+	if len(cols) != 1 {
+		return "", fmt.Errorf("RETURNING emulation requires a single-column primary key on %s", table)
 	}
-	return res.RowsAffected()
+	return cols[0], nil
 }
 
-func (tx *MySQLTx) Exec(cond *types.ConditionExpr) (int64, error) {
-	sqlStr, args, err := tx.driver.Parser().ParseAndCache(types.OpExec, cond, nil)
-	if err != nil {
-		return 0, err
+// fieldValue 在插入数据中查找字段值
+func fieldValue(data *types.ConditionExpr, field string) (interface{}, bool) {
+	if data == nil {
+		return nil, false
 	}
-	res, err := tx.tx.Exec(sqlStr, args...)
-	if err != nil {
-		return 0, err
+	for _, expr := range data.Exprs {
+		if expr.Field == field {
+			return expr.Value, true
+		}
 	}
-	return res.RowsAffected()
+	return nil, false
 }
 
-func (tx *MySQLTx) Commit() error {
-	return tx.tx.Commit()
-}
+// MySQLConn 实现 dbhelper.Conn
+type MySQLConn = sqlbase.Conn
 
-func (tx *MySQLTx) Rollback() error {
-	return tx.tx.Rollback()
-}
+// MySQLTx 实现 dbhelper.Tx
+type MySQLTx = sqlbase.Tx
@@ -154,3 +154,45 @@ func TestMySQLDriver_Tx(t *testing.T) {
 	}
 	t.Log("事务提交成功")
 }
+
+// RETURNING 模拟测试
+func TestMySQLDriver_Returning(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: mysql.DriverName,
+		DSN:    "test:test@tcp(127.0.0.1:3306)/test?charset=utf8mb4&parseTime=True&loc=Local",
+	})
+	if err != nil {
+		t.Skipf("跳过：未能连接到MySQL数据库: %v", err)
+		return
+	}
+	createTable := dbhelper.Cond().Raw("CREATE TABLE IF NOT EXISTS returning_user (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR(32), age INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Skipf("跳过：未能连接到MySQL数据库: %v", err)
+		return
+	}
+	defer db.Exec(dbhelper.Cond().Raw("DROP TABLE IF EXISTS returning_user").Build())
+
+	rows, err := db.InsertReturning("returning_user", dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).Build(), []string{"id", "name"})
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("InsertReturning失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("id") == 0 || rows.GetString("name") != "Tom" {
+		t.Fatalf("InsertReturning结果错误: %v", rows.All())
+	}
+
+	cond := dbhelper.Cond().Eq("name", "Tom").Build()
+	rows, err = db.UpdateReturning("returning_user", cond, dbhelper.Cond().Eq("age", 21).Build(), []string{"age"})
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("UpdateReturning失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("age") != 21 {
+		t.Fatalf("UpdateReturning结果错误: %v", rows.All())
+	}
+
+	rows, err = db.DeleteReturning("returning_user", cond, []string{"name"})
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("DeleteReturning失败: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
+	"github.com/Kaguya154/dbhelper/types"
+)
+
+// schema 通过 information_schema 查询当前库（DATABASE()）的结构
+type schema struct{}
+
+func (schema) ListTables(ctx context.Context, q sqlbase.Querier) ([]string, error) {
+	return sqlbase.QueryStrings(ctx, q, "SELECT TABLE_NAME FROM information_schema.TABLES "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME")
+}
+
+func (schema) Columns(ctx context.Context, q sqlbase.Querier, table string) ([]types.ColumnInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COLUMN_KEY, EXTRA "+
+		"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []types.ColumnInfo
+	for rows.Next() {
+		var col types.ColumnInfo
+		var nullable, key, extra string
+		var def sql.NullString
+		if err := rows.Scan(&col.Name, &col.Type, &nullable, &def, &key, &extra); err != nil {
+			return nil, err
+		}
+		col.Nullable = nullable == "YES"
+		if def.Valid {
+			col.Default = &def.String
+		}
+		col.PrimaryKey = key == "PRI"
+		col.AutoIncrement = strings.Contains(strings.ToLower(extra), "auto_increment")
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
+
+func (schema) Indexes(ctx context.Context, q sqlbase.Querier, table string) ([]types.IndexInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT INDEX_NAME, NON_UNIQUE, COALESCE(COLUMN_NAME, '') FROM information_schema.STATISTICS "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []types.IndexInfo
+	for rows.Next() {
+		var name, col string
+		var nonUnique int
+		if err := rows.Scan(&name, &nonUnique, &col); err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, types.IndexInfo{Name: name, Unique: nonUnique == 0, Primary: name == "PRIMARY"})
+		}
+		idx := &indexes[len(indexes)-1]
+		idx.Columns = append(idx.Columns, col)
+	}
+	return indexes, rows.Err()
+}
+
+func (schema) PrimaryKey(ctx context.Context, q sqlbase.Querier, table string) ([]string, error) {
+	return sqlbase.QueryStrings(ctx, q, "SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION", table)
+}
+
+func (schema) ForeignKeys(ctx context.Context, q sqlbase.Querier, table string) ([]types.ForeignKeyInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE "+
+		"FROM information_schema.KEY_COLUMN_USAGE k JOIN information_schema.REFERENTIAL_CONSTRAINTS r "+
+		"ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.TABLE_NAME = k.TABLE_NAME AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME "+
+		"WHERE k.TABLE_SCHEMA = DATABASE() AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL "+
+		"ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fks []types.ForeignKeyInfo
+	for rows.Next() {
+		var name, col, refTable, refCol, onUpdate, onDelete string
+		if err := rows.Scan(&name, &col, &refTable, &refCol, &onUpdate, &onDelete); err != nil {
+			return nil, err
+		}
+		if len(fks) == 0 || fks[len(fks)-1].Name != name {
+			fks = append(fks, types.ForeignKeyInfo{Name: name, RefTable: refTable, OnUpdate: onUpdate, OnDelete: onDelete})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, col)
+		fk.RefColumns = append(fk.RefColumns, refCol)
+	}
+	return fks, rows.Err()
+}
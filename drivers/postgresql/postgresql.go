@@ -2,45 +2,52 @@ package postgresql
 
 import (
 	"database/sql"
-	"fmt"
+	"errors"
 
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
 	"github.com/Kaguya154/dbhelper/parser"
 	"github.com/Kaguya154/dbhelper/types"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const DriverName = "postgres"
 const DriverID uint8 = 2
 
 func GetDriver() *PostgreSQLDriver {
-	return &PostgreSQLDriver{
-		parser: &parser.SQLParser{
-			DriverName: DriverName,
-			DriverID:   DriverID,
-			QuoteFunc:  func(identifier string) string { return "\"" + identifier + "\"" },
-		},
-	}
+	d := &PostgreSQLDriver{}
+	d.parser = &parser.SQLParser{
+		DriverName:      DriverName,
+		DriverID:        DriverID,
+		QuoteFunc:       func(identifier string) string { return "\"" + identifier + "\"" },
+		PlaceholderFunc: d.Placeholder,
+		Dialect:         parser.DialectPostgreSQL,
+	}
+	d.dialect = &sqlbase.Dialect{
+		Parser:    d.parser,
+		Quote:     d.Quote,
+		MaxParams: maxParams,
+		// lib/pq 不支持 LastInsertId：Insert 返回影响行数，InsertMany 不返回 ID
+		InsertID:      sql.Result.RowsAffected,
+		ClassifyError: d.ClassifyError,
+		Schema:        schema{},
+	}
+	return d
 }
 
 // PostgreSQLDriver 实现 dbhelper.Driver
 
 type PostgreSQLDriver struct {
-	parser types.DSLParser
+	parser  types.DSLParser
+	dialect *sqlbase.Dialect
 }
 
 func (d *PostgreSQLDriver) Open(cfg types.DBConfig) (types.Conn, error) {
-	conn, err := sql.Open(DriverName, cfg.DSN)
+	conn, err := sqlbase.Open(DriverName, cfg, d.dialect)
 	if err != nil {
 		return nil, err
 	}
-	if cfg.MaxOpen > 0 {
-		conn.SetMaxOpenConns(cfg.MaxOpen)
-	}
-	if cfg.MaxIdle > 0 {
-		conn.SetMaxIdleConns(cfg.MaxIdle)
-	}
-	return &PostgreSQLConn{conn: conn, driver: d}, nil
+	return conn, nil
 }
 
 func (d *PostgreSQLDriver) Quote(identifier string) string {
@@ -48,205 +55,57 @@ func (d *PostgreSQLDriver) Quote(identifier string) string {
 }
 
 func (d *PostgreSQLDriver) Placeholder(n int) string {
-	return fmt.Sprintf("$%d", n)
+	return parser.DollarPlaceholder(n)
 }
 
 func (d *PostgreSQLDriver) Parser() types.DSLParser {
 	return d.parser
 }
 
-// PostgreSQLConn 实现 dbhelper.Conn
-
-type PostgreSQLConn struct {
-	conn   *sql.DB
-	driver *PostgreSQLDriver
-}
-
-func (db *PostgreSQLConn) Begin() (types.Tx, error) {
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return nil, err
-	}
-	return &PostgreSQLTx{tx: tx, driver: db.driver}, nil
+// IsRetryable 序列化失败（40001）与死锁（40P01）可以通过重试整个事务解决
+func (d *PostgreSQLDriver) IsRetryable(err error) bool {
+	de := d.ClassifyError(err)
+	return de != nil && (de.Kind == types.ErrDeadlock || de.Kind == types.ErrSerialization)
 }
 
-func (db *PostgreSQLConn) Insert(table string, data *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpInsert, data, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (db *PostgreSQLConn) Query(table string, cond *types.ConditionExpr) (*types.Rows, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpQuery, cond, nil)
-	if err != nil {
-		return nil, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	rows, err := db.conn.Query(query, args...)
-	if err != nil {
-		return nil, err
+// ClassifyError 按 SQLSTATE 分类，表名与约束名取自服务端返回的错误字段
+func (d *PostgreSQLDriver) ClassifyError(err error) *types.DBError {
+	if errors.Is(err, pq.ErrSSLNotSupported) {
+		return &types.DBError{Kind: types.ErrConnection}
 	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	result := []map[string]interface{}{}
-	for rows.Next() {
-		row := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range row {
-			rowPtrs[i] = &row[i]
-		}
-		if err := rows.Scan(rowPtrs...); err != nil {
-			return nil, err
-		}
-		m := map[string]interface{}{}
-		for i, col := range columns {
-			m[col] = row[i]
-		}
-		result = append(result, m)
+	var pe *pq.Error
+	if !errors.As(err, &pe) {
+		return nil
 	}
-	return types.NewRows(result), nil
-}
-
-func (db *PostgreSQLConn) Update(table string, where, set *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpUpdate, where, set)
-	if err != nil {
-		return 0, err
+	var kind error
+	switch {
+	case pe.Code == "23505":
+		kind = types.ErrUniqueViolation
+	case pe.Code == "23503":
+		kind = types.ErrForeignKeyViolation
+	case pe.Code == "23502":
+		kind = types.ErrNotNullViolation
+	case pe.Code == "40P01":
+		kind = types.ErrDeadlock
+	case pe.Code == "40001":
+		kind = types.ErrSerialization
+	case pe.Code.Class() == "08" || pe.Code == "57P01" || pe.Code == "53300":
+		kind = types.ErrConnection
+	default:
+		return nil
 	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
+	constraint := pe.Constraint
+	if kind == types.ErrNotNullViolation {
+		constraint = pe.Column
 	}
-	return res.RowsAffected()
+	return &types.DBError{Kind: kind, Table: pe.Table, Constraint: constraint}
 }
 
-func (db *PostgreSQLConn) Delete(table string, cond *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpDelete, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
+// maxParams PostgreSQL 协议的参数上限
+const maxParams = 65535
 
-func (db *PostgreSQLConn) Exec(cond *types.ConditionExpr) (int64, error) {
-	sqlStr, args, err := db.driver.Parser().ParseAndCache(types.OpExec, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	res, err := db.conn.Exec(sqlStr, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
+// PostgreSQLConn 实现 dbhelper.Conn
+type PostgreSQLConn = sqlbase.Conn
 
 // PostgreSQLTx 实现 dbhelper.Tx
-
-type PostgreSQLTx struct {
-	tx     *sql.Tx
-	driver *PostgreSQLDriver
-}
-
-func (tx *PostgreSQLTx) Query(table string, cond *types.ConditionExpr) (*types.Rows, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpQuery, cond, nil)
-	if err != nil {
-		return nil, err
-	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	rows, err := tx.tx.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	result := []map[string]interface{}{}
-	for rows.Next() {
-		row := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range row {
-			rowPtrs[i] = &row[i]
-		}
-		if err := rows.Scan(rowPtrs...); err != nil {
-			return nil, err
-		}
-		m := map[string]interface{}{}
-		for i, col := range columns {
-			m[col] = row[i]
-		}
-		result = append(result, m)
-	}
-	return types.NewRows(result), nil
-}
-
-func (tx *PostgreSQLTx) Insert(table string, data *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpInsert, data, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (tx *PostgreSQLTx) Update(table string, where, set *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpUpdate, where, set)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (tx *PostgreSQLTx) Delete(table string, cond *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpDelete, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (tx *PostgreSQLTx) Exec(cond *types.ConditionExpr) (int64, error) {
-	sqlStr, args, err := tx.driver.Parser().ParseAndCache(types.OpExec, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	res, err := tx.tx.Exec(sqlStr, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (tx *PostgreSQLTx) Commit() error {
-	return tx.tx.Commit()
-}
-
-func (tx *PostgreSQLTx) Rollback() error {
-	return tx.tx.Rollback()
-}
+type PostgreSQLTx = sqlbase.Tx
@@ -0,0 +1,120 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
+	"github.com/Kaguya154/dbhelper/types"
+)
+
+// schema 通过 pg_catalog 查询 search_path 中可见的表结构，需要 PostgreSQL 11 及以上
+type schema struct{}
+
+// tableOID 按 search_path 解析表名，表不存在时为 NULL
+const tableOID = "(SELECT c.oid FROM pg_catalog.pg_class c WHERE c.relname = $1 " +
+	"AND c.relkind IN ('r', 'p') AND pg_catalog.pg_table_is_visible(c.oid))"
+
+// refActions pg_constraint 中引用动作的编码
+var refActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+func (schema) ListTables(ctx context.Context, q sqlbase.Querier) ([]string, error) {
+	return sqlbase.QueryStrings(ctx, q, "SELECT c.relname FROM pg_catalog.pg_class c "+
+		"JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace "+
+		"WHERE c.relkind IN ('r', 'p') AND pg_catalog.pg_table_is_visible(c.oid) "+
+		"AND n.nspname NOT IN ('pg_catalog', 'information_schema') ORDER BY c.relname")
+}
+
+func (schema) Columns(ctx context.Context, q sqlbase.Querier, table string) ([]types.ColumnInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT a.attname, pg_catalog.format_type(a.atttypid, a.atttypmod), NOT a.attnotnull, "+
+		"pg_catalog.pg_get_expr(d.adbin, d.adrelid), COALESCE(a.attnum = ANY(i.indkey::int2[]), false), "+
+		"a.attidentity <> '' OR COALESCE(pg_catalog.pg_get_expr(d.adbin, d.adrelid) LIKE 'nextval(%', false) "+
+		"FROM pg_catalog.pg_attribute a "+
+		"LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum "+
+		"LEFT JOIN pg_catalog.pg_index i ON i.indrelid = a.attrelid AND i.indisprimary "+
+		"WHERE a.attrelid = "+tableOID+" AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []types.ColumnInfo
+	for rows.Next() {
+		var col types.ColumnInfo
+		var def sql.NullString
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &def, &col.PrimaryKey, &col.AutoIncrement); err != nil {
+			return nil, err
+		}
+		if def.Valid {
+			col.Default = &def.String
+		}
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
+
+func (schema) Indexes(ctx context.Context, q sqlbase.Querier, table string) ([]types.IndexInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT ic.relname, i.indisunique, i.indisprimary, COALESCE(a.attname, '') "+
+		"FROM pg_catalog.pg_index i JOIN pg_catalog.pg_class ic ON ic.oid = i.indexrelid "+
+		"CROSS JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord) "+
+		"LEFT JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum "+
+		"WHERE i.indrelid = "+tableOID+" AND k.ord <= i.indnkeyatts ORDER BY ic.relname, k.ord", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []types.IndexInfo
+	for rows.Next() {
+		var idx types.IndexInfo
+		var col string
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Primary, &col); err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != idx.Name {
+			indexes = append(indexes, idx)
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, col)
+	}
+	return indexes, rows.Err()
+}
+
+func (schema) PrimaryKey(ctx context.Context, q sqlbase.Querier, table string) ([]string, error) {
+	return sqlbase.QueryStrings(ctx, q, "SELECT a.attname FROM pg_catalog.pg_index i "+
+		"CROSS JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord) "+
+		"JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum "+
+		"WHERE i.indrelid = "+tableOID+" AND i.indisprimary ORDER BY k.ord", table)
+}
+
+func (schema) ForeignKeys(ctx context.Context, q sqlbase.Querier, table string) ([]types.ForeignKeyInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT con.conname, a.attname, rc.relname, ra.attname, con.confupdtype::text, con.confdeltype::text "+
+		"FROM pg_catalog.pg_constraint con "+
+		"CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refnum, ord) "+
+		"JOIN pg_catalog.pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum "+
+		"JOIN pg_catalog.pg_class rc ON rc.oid = con.confrelid "+
+		"JOIN pg_catalog.pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refnum "+
+		"WHERE con.contype = 'f' AND con.conrelid = "+tableOID+" ORDER BY con.conname, k.ord", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fks []types.ForeignKeyInfo
+	for rows.Next() {
+		var name, col, refTable, refCol, onUpdate, onDelete string
+		if err := rows.Scan(&name, &col, &refTable, &refCol, &onUpdate, &onDelete); err != nil {
+			return nil, err
+		}
+		if len(fks) == 0 || fks[len(fks)-1].Name != name {
+			fks = append(fks, types.ForeignKeyInfo{Name: name, RefTable: refTable, OnUpdate: refActions[onUpdate], OnDelete: refActions[onDelete]})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, col)
+		fk.RefColumns = append(fk.RefColumns, refCol)
+	}
+	return fks, rows.Err()
+}
@@ -0,0 +1,118 @@
+package sqlbase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Kaguya154/dbhelper/types"
+)
+
+// Schema 方言的结构查询实现，table 为未加引号的表名
+type Schema interface {
+	ListTables(ctx context.Context, q Querier) ([]string, error)
+	Columns(ctx context.Context, q Querier, table string) ([]types.ColumnInfo, error)
+	Indexes(ctx context.Context, q Querier, table string) ([]types.IndexInfo, error)
+	PrimaryKey(ctx context.Context, q Querier, table string) ([]string, error)
+	ForeignKeys(ctx context.Context, q Querier, table string) ([]types.ForeignKeyInfo, error)
+}
+
+// QueryStrings 执行只返回一列字符串的查询
+func QueryStrings(ctx context.Context, q Querier, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// schema 返回方言的结构查询实现，方言未提供时返回错误
+func (b *base) schema() (Schema, error) {
+	if b.d.Schema == nil {
+		return nil, fmt.Errorf("schema inspection is not supported by this driver")
+	}
+	return b.d.Schema, nil
+}
+
+func (b *base) ListTables() ([]string, error) {
+	return b.ListTablesContext(context.Background())
+}
+
+// ListTablesContext 列出当前库中的表，按名称排序
+func (b *base) ListTablesContext(ctx context.Context) ([]string, error) {
+	s, err := b.schema()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := s.ListTables(ctx, b.q)
+	return tables, b.d.Wrap(err, "", "")
+}
+
+func (b *base) Columns(table string) ([]types.ColumnInfo, error) {
+	return b.ColumnsContext(context.Background(), table)
+}
+
+// ColumnsContext 按定义顺序返回表的列，表不存在时返回 ErrNotFound
+func (b *base) ColumnsContext(ctx context.Context, table string) ([]types.ColumnInfo, error) {
+	s, err := b.schema()
+	if err != nil {
+		return nil, err
+	}
+	cols, err := s.Columns(ctx, b.q, table)
+	if err != nil {
+		return nil, b.d.Wrap(err, table, "")
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("table %s: %w", table, types.ErrNotFound)
+	}
+	return cols, nil
+}
+
+func (b *base) Indexes(table string) ([]types.IndexInfo, error) {
+	return b.IndexesContext(context.Background(), table)
+}
+
+// IndexesContext 返回表的索引（含主键与唯一约束对应的索引），按名称排序
+func (b *base) IndexesContext(ctx context.Context, table string) ([]types.IndexInfo, error) {
+	s, err := b.schema()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := s.Indexes(ctx, b.q, table)
+	return idx, b.d.Wrap(err, table, "")
+}
+
+func (b *base) PrimaryKey(table string) ([]string, error) {
+	return b.PrimaryKeyContext(context.Background(), table)
+}
+
+func (b *base) PrimaryKeyContext(ctx context.Context, table string) ([]string, error) {
+	s, err := b.schema()
+	if err != nil {
+		return nil, err
+	}
+	pk, err := s.PrimaryKey(ctx, b.q, table)
+	return pk, b.d.Wrap(err, table, "")
+}
+
+func (b *base) ForeignKeys(table string) ([]types.ForeignKeyInfo, error) {
+	return b.ForeignKeysContext(context.Background(), table)
+}
+
+// ForeignKeysContext 返回表上定义的外键
+func (b *base) ForeignKeysContext(ctx context.Context, table string) ([]types.ForeignKeyInfo, error) {
+	s, err := b.schema()
+	if err != nil {
+		return nil, err
+	}
+	fks, err := s.ForeignKeys(ctx, b.q, table)
+	return fks, b.d.Wrap(err, table, "")
+}
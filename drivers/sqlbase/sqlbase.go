@@ -0,0 +1,573 @@
+package sqlbase
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/Kaguya154/dbhelper/types"
+)
+
+// Dialect 驱动方言描述，Conn 与 Tx 据此在 *sql.DB / *sql.Tx 上实现 dbhelper.Conn 与 dbhelper.Tx
+type Dialect struct {
+	Parser types.DSLParser
+	Quote  func(identifier string) string
+	// MaxParams 单条语句的参数上限，InsertMany 据此拆分批次
+	MaxParams int
+	// InsertID 单行插入的返回值，为空时使用 LastInsertId
+	InsertID func(res sql.Result) (int64, error)
+	// BatchInsertIDs 多行插入时按顺序给出每行的 ID，为空时不返回 ID；只应在 ID 确定可靠时提供
+	BatchInsertIDs func(res sql.Result, n int) []int64
+	// Returning 执行 *Returning 系列方法，为空时使用 QueryReturning
+	Returning func(ctx context.Context, q Querier, d *Dialect, op types.OpType, table string, where, set *types.ConditionExpr, cols []string) (*types.Rows, error)
+	// ClassifyError 将原始驱动错误映射为 *types.DBError，为空或返回 nil 时只识别通用的连接错误
+	ClassifyError func(err error) *types.DBError
+	// Schema 结构查询实现，为空时 Inspector 系列方法返回错误
+	Schema Schema
+}
+
+// Wrap 将驱动错误分类为 *types.DBError 并补充表名与 SQL，无法分类或已分类的错误原样返回
+func (d *Dialect) Wrap(err error, table, query string) error {
+	if err == nil {
+		return nil
+	}
+	var de *types.DBError
+	if errors.As(err, &de) {
+		return err
+	}
+	if d.ClassifyError != nil {
+		de = d.ClassifyError(err)
+	}
+	if de == nil && isConnError(err) {
+		de = &types.DBError{Kind: types.ErrConnection}
+	}
+	if de == nil {
+		return err
+	}
+	de.Err = err
+	if de.Table == "" {
+		de.Table = table
+	}
+	de.SQL = query
+	return de
+}
+
+// isConnError 判断是否为 database/sql 或网络层的连接错误；
+// context 的超时与取消同样实现了 net.Error，属于请求本身的截止时间，不视为连接错误
+func isConnError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &ne)
+}
+
+// Querier *sql.DB 与 *sql.Tx 共有的执行接口
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Open 打开连接池，应用连接池配置并检查连通性
+func Open(driverName string, cfg types.DBConfig, d *Dialect) (*Conn, error) {
+	db, err := sql.Open(driverName, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupPool(db, cfg); err != nil {
+		return nil, d.Wrap(err, "", "")
+	}
+	return NewConn(db, d), nil
+}
+
+// NewConn 使用已打开的连接池构建 Conn
+func NewConn(db *sql.DB, d *Dialect) *Conn {
+	return &Conn{base: base{q: db, d: d}, db: db}
+}
+
+// setupPool 应用连接池配置并检查连通性，失败时关闭连接池
+func setupPool(conn *sql.DB, cfg types.DBConfig) error {
+	if cfg.MaxOpen > 0 {
+		conn.SetMaxOpenConns(cfg.MaxOpen)
+	}
+	if cfg.MaxIdle > 0 {
+		conn.SetMaxIdleConns(cfg.MaxIdle)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		conn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	if cfg.ConnectTimeout < 0 {
+		return nil
+	}
+	timeout := cfg.ConnectTimeout
+	if timeout == 0 {
+		timeout = types.DefaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// QueryReturning 执行带 RETURNING 子句的语句并读取返回的行
+func QueryReturning(ctx context.Context, q Querier, d *Dialect, op types.OpType, table string, where, set *types.ConditionExpr, cols []string) (*types.Rows, error) {
+	sqlTmpl, args, err := d.Parser.ParseReturningAndCache(op, where, set, cols)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(sqlTmpl, d.Quote(table))
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, d.Wrap(err, table, query)
+	}
+	cur, err := types.NewCursor(rows)
+	if err != nil {
+		return nil, d.Wrap(err, table, query)
+	}
+	res, err := cur.Collect()
+	return res, d.Wrap(err, table, query)
+}
+
+var (
+	countOptions  = &types.QueryOptions{Aggregates: []types.Aggregate{types.Count("*", "count")}}
+	existsOptions = &types.QueryOptions{Limit: 1}
+)
+
+// base Conn 与 Tx 共有的读写方法，q 为 *sql.DB 或 *sql.Tx
+type base struct {
+	q Querier
+	d *Dialect
+}
+
+// exec 执行表模板语句并返回结果，错误经方言分类
+func (b *base) exec(ctx context.Context, sqlTmpl string, args []interface{}, table string) (sql.Result, error) {
+	query := fmt.Sprintf(sqlTmpl, b.d.Quote(table))
+	res, err := b.q.ExecContext(ctx, query, args...)
+	return res, b.d.Wrap(err, table, query)
+}
+
+// query 执行表模板查询并返回游标与实际执行的 SQL
+func (b *base) query(ctx context.Context, sqlTmpl string, args []interface{}, table string) (*types.Cursor, string, error) {
+	query := fmt.Sprintf(sqlTmpl, b.d.Quote(table))
+	rows, err := b.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, query, b.d.Wrap(err, table, query)
+	}
+	cur, err := types.NewCursor(rows)
+	return cur, query, b.d.Wrap(err, table, query)
+}
+
+func (b *base) Insert(table string, data *types.ConditionExpr) (int64, error) {
+	return b.InsertContext(context.Background(), table, data)
+}
+
+func (b *base) InsertContext(ctx context.Context, table string, data *types.ConditionExpr) (int64, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseAndCache(types.OpInsert, data, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := b.exec(ctx, sqlTmpl, args, table)
+	if err != nil {
+		return 0, err
+	}
+	if b.d.InsertID != nil {
+		return b.d.InsertID(res)
+	}
+	return res.LastInsertId()
+}
+
+func (b *base) Query(table string, cond *types.ConditionExpr, opts ...*types.QueryOptions) (*types.Rows, error) {
+	return b.QueryContext(context.Background(), table, cond, opts...)
+}
+
+func (b *base) QueryContext(ctx context.Context, table string, cond *types.ConditionExpr, opts ...*types.QueryOptions) (*types.Rows, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseQueryAndCache(cond, types.PickQueryOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	cur, query, err := b.query(ctx, sqlTmpl, args, table)
+	if err != nil {
+		return nil, err
+	}
+	res, err := cur.Collect()
+	return res, b.d.Wrap(err, table, query)
+}
+
+// QueryIter 流式查询，调用方需在使用完毕后 Close 游标
+func (b *base) QueryIter(table string, cond *types.ConditionExpr, opts ...*types.QueryOptions) (*types.Cursor, error) {
+	return b.QueryIterContext(context.Background(), table, cond, opts...)
+}
+
+func (b *base) QueryIterContext(ctx context.Context, table string, cond *types.ConditionExpr, opts ...*types.QueryOptions) (*types.Cursor, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseQueryAndCache(cond, types.PickQueryOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	cur, _, err := b.query(ctx, sqlTmpl, args, table)
+	return cur, err
+}
+
+// Upsert 插入数据，与 conflictColumns 冲突时更新 updateColumns；updateColumns 为空时忽略冲突行。
+// 返回影响行数
+func (b *base) Upsert(table string, data *types.ConditionExpr, conflictColumns, updateColumns []string) (int64, error) {
+	return b.UpsertContext(context.Background(), table, data, conflictColumns, updateColumns)
+}
+
+func (b *base) UpsertContext(ctx context.Context, table string, data *types.ConditionExpr, conflictColumns, updateColumns []string) (int64, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseUpsertAndCache(data, &types.UpsertOptions{
+		ConflictColumns: conflictColumns,
+		UpdateColumns:   updateColumns,
+		DoNothing:       len(updateColumns) == 0,
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := b.exec(ctx, sqlTmpl, args, table)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// returning 按方言执行 RETURNING 语句
+func (b *base) returning(ctx context.Context, op types.OpType, table string, where, set *types.ConditionExpr, cols []string) (*types.Rows, error) {
+	if b.d.Returning != nil {
+		rows, err := b.d.Returning(ctx, b.q, b.d, op, table, where, set, cols)
+		return rows, b.d.Wrap(err, table, "")
+	}
+	return QueryReturning(ctx, b.q, b.d, op, table, where, set, cols)
+}
+
+// InsertReturning 插入数据并返回 returning 指定的列
+func (b *base) InsertReturning(table string, data *types.ConditionExpr, returning []string) (*types.Rows, error) {
+	return b.InsertReturningContext(context.Background(), table, data, returning)
+}
+
+func (b *base) InsertReturningContext(ctx context.Context, table string, data *types.ConditionExpr, returning []string) (*types.Rows, error) {
+	return b.returning(ctx, types.OpInsert, table, data, nil, returning)
+}
+
+// UpdateReturning 更新数据并返回被更新行的 returning 列
+func (b *base) UpdateReturning(table string, where, set *types.ConditionExpr, returning []string) (*types.Rows, error) {
+	return b.UpdateReturningContext(context.Background(), table, where, set, returning)
+}
+
+func (b *base) UpdateReturningContext(ctx context.Context, table string, where, set *types.ConditionExpr, returning []string) (*types.Rows, error) {
+	return b.returning(ctx, types.OpUpdate, table, where, set, returning)
+}
+
+// DeleteReturning 删除数据并返回被删除行的 returning 列
+func (b *base) DeleteReturning(table string, cond *types.ConditionExpr, returning []string) (*types.Rows, error) {
+	return b.DeleteReturningContext(context.Background(), table, cond, returning)
+}
+
+func (b *base) DeleteReturningContext(ctx context.Context, table string, cond *types.ConditionExpr, returning []string) (*types.Rows, error) {
+	return b.returning(ctx, types.OpDelete, table, cond, nil, returning)
+}
+
+// QueryRaw 执行原始 SQL 查询（CTE、窗口函数、UNION 等）并读取全部结果
+func (b *base) QueryRaw(query string, args ...interface{}) (*types.Rows, error) {
+	return b.QueryRawContext(context.Background(), query, args...)
+}
+
+func (b *base) QueryRawContext(ctx context.Context, query string, args ...interface{}) (*types.Rows, error) {
+	cur, err := b.QueryRawIterContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := cur.Collect()
+	return res, b.d.Wrap(err, "", query)
+}
+
+// QueryRawIter 流式执行原始 SQL 查询，调用方需在使用完毕后 Close 游标
+func (b *base) QueryRawIter(query string, args ...interface{}) (*types.Cursor, error) {
+	return b.QueryRawIterContext(context.Background(), query, args...)
+}
+
+// QueryRawIterContext 与 Exec 一样经解析器将 ?、命名参数与 PostgreSQL 的 $n 改写为方言占位符
+func (b *base) QueryRawIterContext(ctx context.Context, query string, args ...interface{}) (*types.Cursor, error) {
+	sqlStr, args, err := b.d.Parser.ParseAndCache(types.OpExec, types.NewCondition().Raw(query, args...).Build(), nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.q.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, b.d.Wrap(err, "", sqlStr)
+	}
+	cur, err := types.NewCursor(rows)
+	return cur, b.d.Wrap(err, "", sqlStr)
+}
+
+func (b *base) Count(table string, cond *types.ConditionExpr) (int64, error) {
+	return b.CountContext(context.Background(), table, cond)
+}
+
+// CountContext 统计满足条件的行数
+func (b *base) CountContext(ctx context.Context, table string, cond *types.ConditionExpr) (int64, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseQueryAndCache(cond, countOptions)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf(sqlTmpl, b.d.Quote(table))
+	var n int64
+	if err := b.q.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, b.d.Wrap(err, table, query)
+	}
+	return n, nil
+}
+
+func (b *base) Exists(table string, cond *types.ConditionExpr) (bool, error) {
+	return b.ExistsContext(context.Background(), table, cond)
+}
+
+// ExistsContext 判断是否存在满足条件的行，最多读取一行
+func (b *base) ExistsContext(ctx context.Context, table string, cond *types.ConditionExpr) (bool, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseQueryAndCache(cond, existsOptions)
+	if err != nil {
+		return false, err
+	}
+	cur, query, err := b.query(ctx, sqlTmpl, args, table)
+	if err != nil {
+		return false, err
+	}
+	defer cur.Close()
+	found := cur.Next()
+	return found, b.d.Wrap(cur.Err(), table, query)
+}
+
+func (b *base) Update(table string, where, set *types.ConditionExpr) (int64, error) {
+	return b.UpdateContext(context.Background(), table, where, set)
+}
+
+func (b *base) UpdateContext(ctx context.Context, table string, where, set *types.ConditionExpr) (int64, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseAndCache(types.OpUpdate, where, set)
+	if err != nil {
+		return 0, err
+	}
+	res, err := b.exec(ctx, sqlTmpl, args, table)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (b *base) Delete(table string, cond *types.ConditionExpr) (int64, error) {
+	return b.DeleteContext(context.Background(), table, cond)
+}
+
+func (b *base) DeleteContext(ctx context.Context, table string, cond *types.ConditionExpr) (int64, error) {
+	sqlTmpl, args, err := b.d.Parser.ParseAndCache(types.OpDelete, cond, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := b.exec(ctx, sqlTmpl, args, table)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (b *base) Exec(cond *types.ConditionExpr) (int64, error) {
+	return b.ExecContext(context.Background(), cond)
+}
+
+func (b *base) ExecContext(ctx context.Context, cond *types.ConditionExpr) (int64, error) {
+	sqlStr, args, err := b.d.Parser.ParseAndCache(types.OpExec, cond, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := b.q.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, b.d.Wrap(err, "", sqlStr)
+	}
+	return res.RowsAffected()
+}
+
+func (b *base) InsertMany(table string, rows []*types.ConditionExpr) (*types.BatchResult, error) {
+	return b.InsertManyContext(context.Background(), table, rows)
+}
+
+// InsertManyContext 按参数上限拆分为多条多行 INSERT 依次执行
+func (b *base) InsertManyContext(ctx context.Context, table string, rows []*types.ConditionExpr) (*types.BatchResult, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("InsertMany rows cannot be empty")
+	}
+	if rows[0] == nil || len(rows[0].Exprs) == 0 {
+		return nil, fmt.Errorf("Insert data must be AND expr with fields")
+	}
+	chunk := b.d.MaxParams / len(rows[0].Exprs)
+	if chunk == 0 {
+		return nil, fmt.Errorf("InsertMany row has more than %d columns", b.d.MaxParams)
+	}
+	result := &types.BatchResult{}
+	for start := 0; start < len(rows); start += chunk {
+		end := min(start+chunk, len(rows))
+		sqlTmpl, args, err := b.d.Parser.ParseInsertManyAndCache(rows[start:end])
+		if err != nil {
+			return nil, err
+		}
+		res, err := b.exec(ctx, sqlTmpl, args, table)
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		result.RowsAffected += n
+		if b.d.BatchInsertIDs != nil {
+			result.InsertIDs = append(result.InsertIDs, b.d.BatchInsertIDs(res, end-start)...)
+		}
+	}
+	return result, nil
+}
+
+// Conn 实现 dbhelper.Conn
+type Conn struct {
+	base
+	db *sql.DB
+}
+
+// DB 返回底层连接池
+func (db *Conn) DB() *sql.DB {
+	return db.db
+}
+
+// Close 关闭连接池
+func (db *Conn) Close() error {
+	return db.db.Close()
+}
+
+func (db *Conn) Ping() error {
+	return db.PingContext(context.Background())
+}
+
+func (db *Conn) PingContext(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
+
+// Stats 返回连接池统计信息
+func (db *Conn) Stats() sql.DBStats {
+	return db.db.Stats()
+}
+
+func (db *Conn) Begin() (types.Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+func (db *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (types.Tx, error) {
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, db.d.Wrap(err, "", "BEGIN")
+	}
+	return &Tx{base: base{q: tx, d: db.d}, tx: tx, seq: new(int)}, nil
+}
+
+func (db *Conn) InsertMany(table string, rows []*types.ConditionExpr) (*types.BatchResult, error) {
+	return db.InsertManyContext(context.Background(), table, rows)
+}
+
+// InsertManyContext 在事务中分批执行多行插入，任一批失败时整体回滚
+func (db *Conn) InsertManyContext(ctx context.Context, table string, rows []*types.ConditionExpr) (*types.BatchResult, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, db.d.Wrap(err, table, "BEGIN")
+	}
+	res, err := (&base{q: tx, d: db.d}).InsertManyContext(ctx, table, rows)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, db.d.Wrap(err, table, "COMMIT")
+	}
+	return res, nil
+}
+
+// Tx 实现 dbhelper.Tx
+type Tx struct {
+	base
+	tx *sql.Tx
+	// savepoint 非空时为 Begin 创建的嵌套事务，Commit/Rollback 作用于该保存点
+	savepoint string
+	seq       *int
+	done      bool
+}
+
+// Commit 提交事务；嵌套事务释放其保存点，改动随外层事务提交
+func (tx *Tx) Commit() error {
+	if tx.savepoint == "" {
+		return tx.d.Wrap(tx.tx.Commit(), "", "COMMIT")
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	return tx.Release(tx.savepoint)
+}
+
+// Rollback 回滚事务；嵌套事务回滚到其保存点并释放，不影响外层事务
+func (tx *Tx) Rollback() error {
+	if tx.savepoint == "" {
+		return tx.tx.Rollback()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	if err := tx.RollbackTo(tx.savepoint); err != nil {
+		return err
+	}
+	return tx.Release(tx.savepoint)
+}
+
+// Begin 在当前事务中创建保存点并返回嵌套事务，可以多层嵌套
+func (tx *Tx) Begin() (types.Tx, error) {
+	*tx.seq++
+	name := "sp_" + strconv.Itoa(*tx.seq)
+	if err := tx.Savepoint(name); err != nil {
+		return nil, err
+	}
+	return &Tx{base: tx.base, tx: tx.tx, savepoint: name, seq: tx.seq}, nil
+}
+
+// Savepoint 创建保存点，name 只能包含字母、数字与下划线
+func (tx *Tx) Savepoint(name string) error {
+	return tx.execSavepoint("SAVEPOINT ", name)
+}
+
+// RollbackTo 回滚到保存点，保存点本身保留
+func (tx *Tx) RollbackTo(name string) error {
+	return tx.execSavepoint("ROLLBACK TO SAVEPOINT ", name)
+}
+
+// Release 释放保存点
+func (tx *Tx) Release(name string) error {
+	return tx.execSavepoint("RELEASE SAVEPOINT ", name)
+}
+
+// execSavepoint 执行保存点语句，名称会原样写入 SQL，因此只接受标识符
+func (tx *Tx) execSavepoint(stmt, name string) error {
+	if name == "" {
+		return fmt.Errorf("savepoint name cannot be empty")
+	}
+	for _, c := range name {
+		if c != '_' && (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return fmt.Errorf("invalid savepoint name: %q", name)
+		}
+	}
+	query := stmt + tx.d.Quote(name)
+	_, err := tx.tx.Exec(query)
+	return tx.d.Wrap(err, "", query)
+}
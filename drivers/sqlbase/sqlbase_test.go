@@ -0,0 +1,95 @@
+package sqlbase_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
+	"github.com/Kaguya154/dbhelper/parser"
+	"github.com/Kaguya154/dbhelper/types"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 只提供解析器与引号的最小方言，其余行为使用默认值
+func TestConn_MinimalDialect(t *testing.T) {
+	quote := func(identifier string) string { return "\"" + identifier + "\"" }
+	d := &sqlbase.Dialect{
+		Parser: &parser.SQLParser{
+			DriverName:      "sqlbase_test",
+			DriverID:        200,
+			QuoteFunc:       quote,
+			PlaceholderFunc: parser.QuestionPlaceholder,
+			Dialect:         parser.DialectSQLite,
+		},
+		Quote:     quote,
+		MaxParams: 4,
+	}
+	conn, err := sqlbase.Open("sqlite3", types.DBConfig{DSN: ":memory:", MaxOpen: 1}, d)
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	defer conn.Close()
+	var _ types.Conn = conn
+
+	if _, err := conn.Exec(types.NewCondition().Raw("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)").Build()); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	id, err := conn.Insert("items", types.NewCondition().Eq("name", "a").Eq("id", nil).Build())
+	if err != nil || id != 1 {
+		t.Fatalf("插入失败: id=%d err=%v", id, err)
+	}
+
+	// MaxParams 为 4 时每批 4 行，5 行拆为两条语句；未提供 BatchInsertIDs 时不返回 ID
+	rows, err := types.FromMaps([]map[string]interface{}{{"name": "b"}, {"name": "c"}, {"name": "d"}, {"name": "e"}, {"name": "f"}})
+	if err != nil {
+		t.Fatalf("构建数据失败: %v", err)
+	}
+	res, err := conn.InsertMany("items", rows)
+	if err != nil {
+		t.Fatalf("批量插入失败: %v", err)
+	}
+	if res.RowsAffected != 5 || res.InsertIDs != nil {
+		t.Fatalf("批量插入结果错误: %+v", res)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	if _, err := tx.Delete("items", types.NewCondition().Gt("id", 3).Build()); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if err := tx.Rollback(); err != sql.ErrTxDone {
+		t.Fatalf("重复回滚应返回 ErrTxDone，实际: %v", err)
+	}
+
+	got, err := conn.Query("items", nil, &types.QueryOptions{OrderBy: []types.OrderBy{types.Desc("id")}, Limit: 1})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if got.Count() != 1 || !got.Next() || got.GetString("name") != "f" {
+		t.Fatalf("查询结果错误")
+	}
+	n, err := conn.Count("items", nil)
+	if err != nil || n != 6 {
+		t.Fatalf("计数错误: n=%d err=%v", n, err)
+	}
+	if _, err := conn.ListTables(); err == nil {
+		t.Fatalf("未提供 Schema 时应返回错误")
+	}
+
+	// 请求超时不应被当作连接错误
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = conn.QueryContext(ctx, "items", nil)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, types.ErrConnection) {
+		t.Fatalf("期望未分类的 DeadlineExceeded, 实际: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
+	"github.com/Kaguya154/dbhelper/types"
+)
+
+// schema 通过 sqlite_master 与 PRAGMA 表值函数查询结构，需要 SQLite 3.16.0 及以上
+type schema struct{}
+
+func (schema) ListTables(ctx context.Context, q sqlbase.Querier) ([]string, error) {
+	return sqlbase.QueryStrings(ctx, q, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+}
+
+func (schema) Columns(ctx context.Context, q sqlbase.Querier, table string) ([]types.ColumnInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []types.ColumnInfo
+	pkCount := 0
+	rowid := -1
+	for rows.Next() {
+		var col types.ColumnInfo
+		var notNull bool
+		var def sql.NullString
+		var pk int
+		if err := rows.Scan(&col.Name, &col.Type, &notNull, &def, &pk); err != nil {
+			return nil, err
+		}
+		col.Nullable = !notNull
+		if def.Valid {
+			col.Default = &def.String
+		}
+		if pk > 0 {
+			col.PrimaryKey = true
+			pkCount++
+			if strings.EqualFold(col.Type, "INTEGER") {
+				rowid = len(cols)
+			}
+		}
+		cols = append(cols, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// 单列 INTEGER PRIMARY KEY 是 rowid 的别名，由 SQLite 自动分配
+	if pkCount == 1 && rowid >= 0 {
+		cols[rowid].AutoIncrement = true
+	}
+	return cols, nil
+}
+
+func (schema) Indexes(ctx context.Context, q sqlbase.Querier, table string) ([]types.IndexInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name, \"unique\", origin FROM pragma_index_list(?) ORDER BY name", table)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []types.IndexInfo
+	for rows.Next() {
+		var idx types.IndexInfo
+		var origin string
+		if err := rows.Scan(&idx.Name, &idx.Unique, &origin); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		idx.Primary = origin == "pk"
+		indexes = append(indexes, idx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// 读完索引列表后再逐个查询索引列，避免在事务或单连接上嵌套打开结果集
+	for i := range indexes {
+		cols, err := q.QueryContext(ctx, "SELECT COALESCE(name, '') FROM pragma_index_info(?) ORDER BY seqno", indexes[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		for cols.Next() {
+			var name string
+			if err := cols.Scan(&name); err != nil {
+				cols.Close()
+				return nil, err
+			}
+			indexes[i].Columns = append(indexes[i].Columns, name)
+		}
+		cols.Close()
+		if err := cols.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+func (schema) PrimaryKey(ctx context.Context, q sqlbase.Querier, table string) ([]string, error) {
+	return sqlbase.QueryStrings(ctx, q, "SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", table)
+}
+
+func (s schema) ForeignKeys(ctx context.Context, q sqlbase.Querier, table string) ([]types.ForeignKeyInfo, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, \"table\", \"from\", \"to\", on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq", table)
+	if err != nil {
+		return nil, err
+	}
+	var fks []types.ForeignKeyInfo
+	implicit := map[int]bool{}
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var refTable, from, onUpdate, onDelete string
+		var to sql.NullString
+		if err := rows.Scan(&id, &refTable, &from, &to, &onUpdate, &onDelete); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if id != lastID {
+			fks = append(fks, types.ForeignKeyInfo{RefTable: refTable, OnUpdate: onUpdate, OnDelete: onDelete})
+			lastID = id
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, from)
+		fk.RefColumns = append(fk.RefColumns, to.String)
+		if !to.Valid {
+			implicit[len(fks)-1] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// REFERENCES 未写列名时引用被引用表的主键
+	for i := range implicit {
+		pk, err := s.PrimaryKey(ctx, q, fks[i].RefTable)
+		if err != nil {
+			return nil, err
+		}
+		if len(pk) == len(fks[i].RefColumns) {
+			fks[i].RefColumns = pk
+		}
+	}
+	return fks, nil
+}
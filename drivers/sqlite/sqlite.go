@@ -1,46 +1,56 @@
 package sqlite
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/Kaguya154/dbhelper/drivers/sqlbase"
 	"github.com/Kaguya154/dbhelper/parser"
 	"github.com/Kaguya154/dbhelper/types"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 // SQLiteDriver 实现 dbhelper.Driver
 
 func GetDriver() *SQLiteDriver {
-	return &SQLiteDriver{
-		parser: &parser.SQLParser{
-			DriverName: DriverName,
-			DriverID:   DriverID,
-			QuoteFunc:  func(identifier string) string { return "`" + identifier + "`" },
-		},
-	}
+	d := &SQLiteDriver{}
+	d.parser = &parser.SQLParser{
+		DriverName:      DriverName,
+		DriverID:        DriverID,
+		QuoteFunc:       func(identifier string) string { return "`" + identifier + "`" },
+		PlaceholderFunc: d.Placeholder,
+		Dialect:         parser.DialectSQLite,
+	}
+	d.dialect = &sqlbase.Dialect{
+		Parser:    d.parser,
+		Quote:     d.Quote,
+		MaxParams: maxParams,
+		// 不提供 BatchInsertIDs：行数据可以显式指定 rowid，无法由 LastInsertId 推算每行的 ID；
+		// RETURNING 的输出顺序也不保证与 VALUES 一致，因此 InsertMany 不返回 ID
+		Returning:     returningRows,
+		ClassifyError: d.ClassifyError,
+		Schema:        schema{},
+	}
+	return d
 }
 
 type SQLiteDriver struct {
-	parser types.DSLParser
+	parser  types.DSLParser
+	dialect *sqlbase.Dialect
 }
 
 const DriverName = "sqlite3"
 const DriverID uint8 = 0
 
 func (d *SQLiteDriver) Open(cfg types.DBConfig) (types.Conn, error) {
-	conn, err := sql.Open(DriverName, cfg.DSN)
+	conn, err := sqlbase.Open(DriverName, cfg, d.dialect)
 	if err != nil {
 		return nil, err
 	}
-	if cfg.MaxOpen > 0 {
-		conn.SetMaxOpenConns(cfg.MaxOpen)
-	}
-	if cfg.MaxIdle > 0 {
-		conn.SetMaxIdleConns(cfg.MaxIdle)
-	}
-	return &SQLiteConn{conn: conn, driver: d}, nil
+	return conn, nil
 }
 
 func (d *SQLiteDriver) Quote(identifier string) string {
@@ -48,210 +58,70 @@ func (d *SQLiteDriver) Quote(identifier string) string {
 }
 
 func (d *SQLiteDriver) Placeholder(n int) string {
-	return "?"
+	return parser.QuestionPlaceholder(n)
 }
 
 func (d *SQLiteDriver) Parser() types.DSLParser {
 	return d.parser
 }
 
-// SQLiteConn 实现 dbhelper.Conn
-type SQLiteConn struct {
-	conn   *sql.DB
-	driver *SQLiteDriver
-}
-
-func (db *SQLiteConn) Begin() (types.Tx, error) {
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return nil, err
-	}
-	return &SQLiteTx{tx: tx, driver: db.driver}, nil
-}
-
-func (db *SQLiteConn) Insert(table string, data *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpInsert, data, nil)
-	if err != nil {
-		return 0, err
-	}
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
-}
-
-func (db *SQLiteConn) Query(table string, cond *types.ConditionExpr) (*types.Rows, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpQuery, cond, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-
-	rows, err := db.conn.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	result := []map[string]interface{}{}
-	for rows.Next() {
-		row := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range row {
-			rowPtrs[i] = &row[i]
-		}
-		if err := rows.Scan(rowPtrs...); err != nil {
-			return nil, err
-		}
-		m := map[string]interface{}{}
-		for i, col := range columns {
-			m[col] = row[i]
+// IsRetryable 数据库或表被其他连接锁定（SQLITE_BUSY、SQLITE_LOCKED）时可以重试整个事务
+func (d *SQLiteDriver) IsRetryable(err error) bool {
+	de := d.ClassifyError(err)
+	return de != nil && de.Kind == types.ErrDeadlock
+}
+
+// ClassifyError 按扩展错误码分类，约束名取自错误信息中的 "表.列"
+func (d *SQLiteDriver) ClassifyError(err error) *types.DBError {
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return nil
+	}
+	var kind error
+	switch {
+	case se.ExtendedCode == sqlite3.ErrConstraintUnique || se.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
+		kind = types.ErrUniqueViolation
+	case se.ExtendedCode == sqlite3.ErrConstraintForeignKey:
+		kind = types.ErrForeignKeyViolation
+	case se.ExtendedCode == sqlite3.ErrConstraintNotNull:
+		kind = types.ErrNotNullViolation
+	case se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked:
+		kind = types.ErrDeadlock
+	case se.Code == sqlite3.ErrCantOpen || se.Code == sqlite3.ErrNotADB:
+		kind = types.ErrConnection
+	default:
+		return nil
+	}
+	de := &types.DBError{Kind: kind}
+	// 如 "UNIQUE constraint failed: users.email"，多列时以逗号分隔
+	if _, cols, ok := strings.Cut(se.Error(), "constraint failed: "); ok {
+		de.Constraint = cols
+		if table, _, ok := strings.Cut(cols, "."); ok {
+			de.Table = table
 		}
-		result = append(result, m)
-	}
-	return types.NewRows(result), nil
-}
-
-func (db *SQLiteConn) Update(table string, where, set *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpUpdate, where, set)
-	if err != nil {
-		return 0, err
-	}
-
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
 	}
-	return res.RowsAffected()
+	return de
 }
 
-func (db *SQLiteConn) Delete(table string, cond *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := db.driver.Parser().ParseAndCache(types.OpDelete, cond, nil)
-	if err != nil {
-		return 0, err
+// maxParams SQLite 单条语句的参数上限，3.32.0 之前为 999
+var maxParams = func() int {
+	_, version, _ := sqlite3.Version()
+	if version < 3032000 {
+		return 999
 	}
+	return 32766
+}()
 
-	query := fmt.Sprintf(sqlTmpl, db.driver.Quote(table))
-	res, err := db.conn.Exec(query, args...)
-	if err != nil {
-		return 0, err
+// returningRows 使用 RETURNING 子句执行 Insert/Update/Delete，需要 SQLite 3.35.0 及以上
+func returningRows(ctx context.Context, q sqlbase.Querier, d *sqlbase.Dialect, op types.OpType, table string, where, set *types.ConditionExpr, cols []string) (*types.Rows, error) {
+	if _, version, _ := sqlite3.Version(); version < 3035000 {
+		return nil, fmt.Errorf("RETURNING requires SQLite 3.35.0 or later")
 	}
-	return res.RowsAffected()
+	return sqlbase.QueryReturning(ctx, q, d, op, table, where, set, cols)
 }
 
-func (db *SQLiteConn) Exec(cond *types.ConditionExpr) (int64, error) {
-	sqlStr, args, err := db.driver.Parser().ParseAndCache(types.OpExec, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	res, err := db.conn.Exec(sqlStr, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
+// SQLiteConn 实现 dbhelper.Conn
+type SQLiteConn = sqlbase.Conn
 
 // SQLiteTx 实现 dbhelper.Tx
-type SQLiteTx struct {
-	tx     *sql.Tx
-	driver *SQLiteDriver
-}
-
-func (tx *SQLiteTx) Query(table string, cond *types.ConditionExpr) (*types.Rows, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpQuery, cond, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	rows, err := tx.tx.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	result := []map[string]interface{}{}
-	for rows.Next() {
-		row := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range row {
-			rowPtrs[i] = &row[i]
-		}
-		if err := rows.Scan(rowPtrs...); err != nil {
-			return nil, err
-		}
-		m := map[string]interface{}{}
-		for i, col := range columns {
-			m[col] = row[i]
-		}
-		result = append(result, m)
-	}
-	return types.NewRows(result), nil
-}
-
-func (tx *SQLiteTx) Insert(table string, data *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpInsert, data, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
-}
-
-func (tx *SQLiteTx) Update(table string, where, set *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpUpdate, where, set)
-	if err != nil {
-		return 0, err
-	}
-
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (tx *SQLiteTx) Delete(table string, cond *types.ConditionExpr) (int64, error) {
-	sqlTmpl, args, err := tx.driver.Parser().ParseAndCache(types.OpDelete, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	query := fmt.Sprintf(sqlTmpl, tx.driver.Quote(table))
-	res, err := tx.tx.Exec(query, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-func (tx *SQLiteTx) Exec(cond *types.ConditionExpr) (int64, error) {
-	sqlStr, args, err := tx.driver.Parser().ParseAndCache(types.OpExec, cond, nil)
-	if err != nil {
-		return 0, err
-	}
-	res, err := tx.tx.Exec(sqlStr, args...)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
-}
-
-func (tx *SQLiteTx) Commit() error {
-	return tx.tx.Commit()
-}
-
-func (tx *SQLiteTx) Rollback() error {
-	return tx.tx.Rollback()
-}
+type SQLiteTx = sqlbase.Tx
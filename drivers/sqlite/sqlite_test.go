@@ -1,10 +1,18 @@
 package sqlite_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
 	"github.com/Kaguya154/dbhelper"
 	"github.com/Kaguya154/dbhelper/drivers/sqlite"
 	"github.com/Kaguya154/dbhelper/types"
-	"testing"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 func init() {
@@ -97,6 +105,7 @@ func TestSQLiteDriver_CRUD(t *testing.T) {
 	if err != nil {
 		t.Fatalf("打开数据库失败: %v", err)
 	}
+	defer db.Close()
 	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
 	// 使用 Exec 方法建表
 	_, err = db.Exec(createTable)
@@ -141,6 +150,7 @@ func TestSQLiteDriver_Tx(t *testing.T) {
 	if err != nil {
 		t.Fatalf("打开数据库失败: %v", err)
 	}
+	defer db.Close()
 	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
 	// 使用 Exec 方法建表
 	_, err = db.Exec(createTable)
@@ -175,3 +185,1311 @@ func TestSQLiteDriver_Tx(t *testing.T) {
 	}
 	t.Log("事务提交成功")
 }
+
+// Context测试
+func TestSQLiteDriver_Context(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
+	if _, err = db.ExecContext(context.Background(), createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	data := dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).Build()
+	if _, err = db.InsertContext(context.Background(), "user", data); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+
+	// 已取消的 context 应当直接返回错误
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cond := dbhelper.Cond().Eq("name", "Tom").Build()
+	if _, err = db.QueryContext(ctx, "user", cond); err == nil {
+		t.Fatalf("期望查询因 context 取消而失败")
+	}
+	if _, err = db.UpdateContext(ctx, "user", cond, dbhelper.Cond().Eq("age", 21).Build()); err == nil {
+		t.Fatalf("期望更新因 context 取消而失败")
+	}
+	if _, err = db.BeginTx(ctx, nil); err == nil {
+		t.Fatalf("期望开启事务因 context 取消而失败")
+	}
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.DeleteContext(ctx, "user", cond); err == nil {
+		t.Fatalf("期望事务内删除因 context 取消而失败")
+	}
+}
+
+// 查询选项测试
+func TestSQLiteDriver_QueryOptions(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	for i, name := range []string{"Tom", "Jerry", "Alice", "Bob", "Tom"} {
+		if _, err = db.Insert("user", dbhelper.Cond().Eq("name", name).Eq("age", 20+i).Build()); err != nil {
+			t.Fatalf("插入失败: %v", err)
+		}
+	}
+
+	rows, err := db.Query("user", nil, &types.QueryOptions{
+		Columns: []string{"name", "age"},
+		OrderBy: []types.OrderBy{types.Desc("age")},
+		Limit:   2,
+		Offset:  1,
+	})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if rows.Count() != 2 {
+		t.Fatalf("查询结果数量错误: %d", rows.Count())
+	}
+	rows.Next()
+	if rows.GetString("name") != "Bob" || rows.GetInt("age") != 23 || rows.Get("id") != nil {
+		t.Fatalf("查询结果错误: %v", rows.All())
+	}
+
+	rows, err = db.Query("user", nil, &types.QueryOptions{Columns: []string{"name"}, Distinct: true, Offset: 1})
+	if err != nil {
+		t.Fatalf("去重查询失败: %v", err)
+	}
+	if rows.Count() != 3 {
+		t.Fatalf("去重查询结果数量错误: %v", rows.All())
+	}
+}
+
+// 流式查询测试
+func TestSQLiteDriver_QueryIter(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	for i, name := range []string{"Tom", "Jerry", "Alice"} {
+		if _, err = db.Insert("user", dbhelper.Cond().Eq("name", name).Eq("age", 20+i).Build()); err != nil {
+			t.Fatalf("插入失败: %v", err)
+		}
+	}
+
+	cur, err := db.QueryIter("user", nil, &types.QueryOptions{OrderBy: []types.OrderBy{types.Asc("id")}})
+	if err != nil {
+		t.Fatalf("流式查询失败: %v", err)
+	}
+	var names []string
+	for cur.Next() {
+		var id, age int
+		var name string
+		if err := cur.Scan(&id, &name, &age); err != nil {
+			t.Fatalf("扫描失败: %v", err)
+		}
+		if cur.GetString("name") != name || cur.GetInt("age") != age {
+			t.Fatalf("访问器结果不一致: %v", cur.Map())
+		}
+		names = append(names, name)
+	}
+	if err := cur.Err(); err != nil {
+		t.Fatalf("遍历失败: %v", err)
+	}
+	cur.Close()
+	if len(names) != 3 || names[0] != "Tom" || names[2] != "Alice" {
+		t.Fatalf("流式查询结果错误: %v", names)
+	}
+
+	// range-over-func，提前退出时关闭游标
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	cur, err = tx.QueryIter("user", dbhelper.Cond().Gt("age", 20).Build())
+	if err != nil {
+		t.Fatalf("事务流式查询失败: %v", err)
+	}
+	count := 0
+	for row, err := range cur.Iter() {
+		if err != nil {
+			t.Fatalf("遍历失败: %v", err)
+		}
+		if row.GetInt("age") <= 20 {
+			t.Fatalf("条件未生效: %v", row.Map())
+		}
+		count++
+		break
+	}
+	if count != 1 {
+		t.Fatalf("迭代次数错误: %d", count)
+	}
+	// 游标已关闭，事务中可以继续执行语句
+	if _, err = tx.Delete("user", dbhelper.Cond().Eq("name", "Tom").Build()); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+}
+
+type baseModel struct {
+	ID int64 `db:"id"`
+}
+
+type userModel struct {
+	baseModel
+	Name    string         `db:"name"`
+	Age     *int           `db:"age"`
+	Email   sql.NullString `db:"email"`
+	Ignored string         `db:"-"`
+}
+
+// 结构体扫描测试
+func TestSQLiteDriver_Scan(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT, email TEXT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	if _, err = db.Insert("user", dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).Eq("email", "tom@example.com").Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+	if _, err = db.Insert("user", dbhelper.Cond().Eq("name", "Jerry").Eq("age", nil).Eq("email", nil).Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+
+	opts := &types.QueryOptions{OrderBy: []types.OrderBy{types.Asc("id")}}
+	rows, err := db.Query("user", nil, opts)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	users, err := types.ScanAll[userModel](rows)
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("扫描结果数量错误: %d", len(users))
+	}
+	if users[0].ID != 1 || users[0].Name != "Tom" || users[0].Age == nil || *users[0].Age != 20 || users[0].Email.String != "tom@example.com" {
+		t.Fatalf("扫描结果错误: %+v", users[0])
+	}
+	if users[1].Age != nil || users[1].Email.Valid {
+		t.Fatalf("NULL 扫描结果错误: %+v", users[1])
+	}
+
+	// 游标 + 指针类型
+	cur, err := db.QueryIter("user", dbhelper.Cond().Eq("name", "Jerry").Build())
+	if err != nil {
+		t.Fatalf("流式查询失败: %v", err)
+	}
+	u, err := types.ScanOne[*userModel](cur)
+	cur.Close()
+	if err != nil || u.Name != "Jerry" || u.ID != 2 {
+		t.Fatalf("游标扫描失败: %v, %+v", err, u)
+	}
+
+	// 单列扫描
+	rows, err = db.Query("user", nil, &types.QueryOptions{Columns: []string{"name"}, OrderBy: opts.OrderBy})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	names, err := types.ScanAll[string](rows)
+	if err != nil || len(names) != 2 || names[1] != "Jerry" {
+		t.Fatalf("单列扫描失败: %v, %v", err, names)
+	}
+
+	// 没有数据
+	rows, err = db.Query("user", dbhelper.Cond().Eq("name", "nobody").Build())
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if _, err = types.ScanOne[userModel](rows); err != sql.ErrNoRows {
+		t.Fatalf("期望 sql.ErrNoRows, 实际: %v", err)
+	}
+
+	// 类型不匹配
+	type badModel struct {
+		Name int `db:"name"`
+	}
+	rows, err = db.Query("user", nil)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if _, err = types.ScanAll[badModel](rows); err == nil {
+		t.Fatalf("期望类型不匹配错误")
+	} else {
+		t.Logf("类型不匹配错误: %v", err)
+	}
+	// NULL 写入非指针字段
+	type strictModel struct {
+		Age int `db:"age"`
+	}
+	rows, err = db.Query("user", dbhelper.Cond().Eq("name", "Jerry").Build())
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if _, err = types.ScanAll[strictModel](rows); err == nil {
+		t.Fatalf("期望 NULL 扫描错误")
+	}
+
+	// 未导出类型的嵌入指针被忽略
+	type ptrModel struct {
+		*baseModel
+		Name string `db:"name"`
+	}
+	rows, err = db.Query("user", nil, opts)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	ptrs, err := types.ScanAll[ptrModel](rows)
+	if err != nil || len(ptrs) != 2 || ptrs[0].baseModel != nil || ptrs[0].Name != "Tom" {
+		t.Fatalf("嵌入指针扫描失败: %v, %+v", err, ptrs)
+	}
+}
+
+type accountModel struct {
+	ID        int64  `db:"id,autoincr"`
+	Name      string `db:"name"`
+	Nick      string `db:"nick,omitempty"`
+	Age       int    `db:"age"`
+	CreatedAt string `db:"created_at,readonly"`
+	Secret    string `db:"-"`
+}
+
+// 结构体/Map 生成数据表达式测试
+func TestSQLiteDriver_FromStruct(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE account (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, nick TEXT DEFAULT 'none', age INT, created_at TEXT DEFAULT 'now')").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+
+	driver, _ := dbhelper.GetDriver(sqlite.DriverName)
+	data, err := types.FromStruct(&accountModel{Name: "Tom", Age: 20, Secret: "x"})
+	if err != nil {
+		t.Fatalf("FromStruct失败: %v", err)
+	}
+	sqlStr, args, err := driver.Parser().Parse(types.OpInsert, data, nil)
+	if err != nil || sqlStr != "INSERT INTO %s (`name`,`age`) VALUES (?,?)" || len(args) != 2 {
+		t.Fatalf("FromStruct生成SQL错误: %s %v %v", sqlStr, args, err)
+	}
+	id, err := db.Insert("account", data)
+	if err != nil || id != 1 {
+		t.Fatalf("插入失败: %v, id=%d", err, id)
+	}
+
+	// 显式设置的自增列与 omitempty 字段会被写入
+	data, _ = types.FromStruct(accountModel{ID: 10, Name: "Jerry", Nick: "J", Age: 30})
+	if id, err = db.Insert("account", data); err != nil || id != 10 {
+		t.Fatalf("插入失败: %v, id=%d", err, id)
+	}
+
+	upd, err := types.FromMap(map[string]interface{}{"nick": "T", "age": 21})
+	if err != nil {
+		t.Fatalf("FromMap失败: %v", err)
+	}
+	sqlStr, _, _ = driver.Parser().Parse(types.OpUpdate, nil, upd)
+	if sqlStr != "UPDATE %s SET `age`=?,`nick`=?" {
+		t.Fatalf("FromMap列顺序错误: %s", sqlStr)
+	}
+	if _, err = db.Update("account", dbhelper.Cond().Eq("id", 1).Build(), upd); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+
+	rows, err := db.Query("account", nil, &types.QueryOptions{OrderBy: []types.OrderBy{types.Asc("id")}})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	accounts, err := types.ScanAll[accountModel](rows)
+	if err != nil || len(accounts) != 2 {
+		t.Fatalf("扫描失败: %v, %v", err, accounts)
+	}
+	if accounts[0].Nick != "T" || accounts[0].Age != 21 || accounts[0].CreatedAt != "now" {
+		t.Fatalf("结果错误: %+v", accounts[0])
+	}
+	if accounts[1].ID != 10 || accounts[1].Nick != "J" {
+		t.Fatalf("结果错误: %+v", accounts[1])
+	}
+
+	if _, err = types.FromStruct(42); err == nil {
+		t.Fatalf("非结构体应返回错误")
+	}
+}
+
+// 批量插入测试
+func TestSQLiteDriver_InsertMany(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+		// 内存数据库每个连接独立，固定为单连接
+		MaxOpen: 1,
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+
+	// 超过单条语句参数上限，需要拆分为多批
+	const total = 20000
+	ms := make([]map[string]interface{}, total)
+	for i := range ms {
+		ms[i] = map[string]interface{}{"name": "user", "age": i}
+	}
+	rows, err := types.FromMaps(ms)
+	if err != nil {
+		t.Fatalf("FromMaps失败: %v", err)
+	}
+	res, err := db.InsertMany("user", rows)
+	if err != nil {
+		t.Fatalf("批量插入失败: %v", err)
+	}
+	if res.RowsAffected != total || res.InsertIDs != nil {
+		t.Fatalf("批量插入结果错误: affected=%d ids=%d", res.RowsAffected, len(res.InsertIDs))
+	}
+	result, err := db.Query("user", dbhelper.Cond().Eq("age", total-1).Build())
+	if err != nil || result.Count() != 1 {
+		t.Fatalf("查询失败: %v", err)
+	}
+	result.Next()
+	if result.GetInt("id") != total {
+		t.Fatalf("ID 对应关系错误: %v", result.All())
+	}
+
+	// 列不一致时整体回滚
+	bad := []*types.ConditionExpr{
+		dbhelper.Cond().Eq("name", "a").Eq("age", 1).Build(),
+		dbhelper.Cond().Eq("age", 2).Eq("name", "b").Build(),
+	}
+	if _, err = db.InsertMany("user", bad); err == nil {
+		t.Fatalf("列不一致应返回错误")
+	}
+
+	// 事务内批量插入
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开始事务失败: %v", err)
+	}
+	res, err = tx.InsertMany("user", rows[:3])
+	if err != nil || res.RowsAffected != 3 {
+		tx.Rollback()
+		t.Fatalf("事务批量插入失败: %v", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	result, err = db.Query("user", nil)
+	if err != nil || result.Count() != total {
+		t.Fatalf("回滚后数据错误: %v, %d", err, result.Count())
+	}
+
+	// 显式指定主键时不能推算 ID
+	explicit := []*types.ConditionExpr{
+		dbhelper.Cond().Eq("id", total+10).Eq("name", "x").Eq("age", 1).Build(),
+		dbhelper.Cond().Eq("id", total+50).Eq("name", "y").Eq("age", 2).Build(),
+	}
+	res, err = db.InsertMany("user", explicit)
+	if err != nil || res.RowsAffected != 2 || res.InsertIDs != nil {
+		t.Fatalf("显式主键批量插入结果错误: %+v, %v", res, err)
+	}
+}
+
+// Upsert测试
+func TestSQLiteDriver_Upsert(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT UNIQUE, name TEXT, age INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	conflict := []string{"email"}
+	if _, err = db.Upsert("user", dbhelper.Cond().Eq("email", "tom@example.com").Eq("name", "Tom").Eq("age", 20).Build(), conflict, []string{"name", "age"}); err != nil {
+		t.Fatalf("upsert插入失败: %v", err)
+	}
+	if _, err = db.Upsert("user", dbhelper.Cond().Eq("email", "tom@example.com").Eq("name", "Tommy").Eq("age", 21).Build(), conflict, []string{"age"}); err != nil {
+		t.Fatalf("upsert更新失败: %v", err)
+	}
+	n, err := db.Upsert("user", dbhelper.Cond().Eq("email", "tom@example.com").Eq("name", "Ignored").Eq("age", 99).Build(), conflict, nil)
+	if err != nil || n != 0 {
+		t.Fatalf("upsert忽略失败: %v, n=%d", err, n)
+	}
+
+	rows, err := db.Query("user", nil)
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("查询失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetString("name") != "Tom" || rows.GetInt("age") != 21 {
+		t.Fatalf("upsert结果错误: %v", rows.All())
+	}
+}
+
+// RETURNING测试
+func TestSQLiteDriver_Returning(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	rows, err := db.InsertReturning("user", dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).Build(), []string{"id", "name"})
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("InsertReturning失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("id") != 1 || rows.GetString("name") != "Tom" {
+		t.Fatalf("InsertReturning结果错误: %v", rows.All())
+	}
+	if _, err = db.Insert("user", dbhelper.Cond().Eq("name", "Jerry").Eq("age", 20).Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+
+	rows, err = db.UpdateReturning("user", dbhelper.Cond().Eq("age", 20).Build(), dbhelper.Cond().Eq("age", 21).Build(), []string{"*"})
+	if err != nil || rows.Count() != 2 {
+		t.Fatalf("UpdateReturning失败: %v", err)
+	}
+	for rows.Next() {
+		if rows.GetInt("age") != 21 {
+			t.Fatalf("UpdateReturning结果错误: %v", rows.All())
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开始事务失败: %v", err)
+	}
+	rows, err = tx.DeleteReturning("user", dbhelper.Cond().Eq("name", "Jerry").Build(), []string{"id"})
+	if err != nil || rows.Count() != 1 {
+		tx.Rollback()
+		t.Fatalf("DeleteReturning失败: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("提交事务失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("id") != 2 {
+		t.Fatalf("DeleteReturning结果错误: %v", rows.All())
+	}
+}
+
+// 聚合查询测试
+func TestSQLiteDriver_Aggregate(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT, amount INT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	for _, o := range [][2]int{{1, 50}, {1, 80}, {2, 30}, {3, 200}} {
+		if _, err = db.Insert("orders", dbhelper.Cond().Eq("user_id", o[0]).Eq("amount", o[1]).Build()); err != nil {
+			t.Fatalf("插入失败: %v", err)
+		}
+	}
+
+	n, err := db.Count("orders", dbhelper.Cond().Eq("user_id", 1).Build())
+	if err != nil || n != 2 {
+		t.Fatalf("计数错误: %d %v", n, err)
+	}
+	ok, err := db.Exists("orders", dbhelper.Cond().Gt("amount", 100).Build())
+	if err != nil || !ok {
+		t.Fatalf("存在判断错误: %v %v", ok, err)
+	}
+	ok, err = db.Exists("orders", dbhelper.Cond().Gt("amount", 1000).Build())
+	if err != nil || ok {
+		t.Fatalf("不存在判断错误: %v %v", ok, err)
+	}
+
+	rows, err := db.Query("orders", nil, &types.QueryOptions{
+		Columns:    []string{"user_id"},
+		Aggregates: []types.Aggregate{types.Count("*", "cnt"), types.Sum("amount", "total")},
+		GroupBy:    []string{"user_id"},
+		Having:     dbhelper.Cond().Gt("total", 100).Build(),
+		OrderBy:    []types.OrderBy{types.Asc("user_id")},
+	})
+	if err != nil {
+		t.Fatalf("聚合查询失败: %v", err)
+	}
+	if rows.Count() != 2 {
+		t.Fatalf("聚合结果数量错误: %v", rows.All())
+	}
+	rows.Next()
+	if rows.GetInt("user_id") != 1 || rows.GetInt("cnt") != 2 || rows.GetInt("total") != 130 {
+		t.Fatalf("聚合结果错误: %v", rows.All())
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	if n, err = tx.Count("orders", nil); err != nil || n != 4 {
+		t.Fatalf("事务计数错误: %d %v", n, err)
+	}
+}
+
+// 联表查询测试
+func TestSQLiteDriver_Join(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT, amount INT)",
+		"INSERT INTO user (name) VALUES ('Tom'), ('Jerry')",
+		"INSERT INTO orders (user_id, amount) VALUES (1, 50), (1, 80)",
+	} {
+		if _, err = db.Exec(dbhelper.Cond().Raw(stmt).Build()); err != nil {
+			t.Fatalf("初始化失败: %v", err)
+		}
+	}
+
+	rows, err := db.Query("user", nil, &types.QueryOptions{
+		Alias:   "u",
+		Joins:   []types.Join{types.LeftJoin("orders", "o", dbhelper.Cond().Eq("o.user_id", types.Col("u.id")).Build())},
+		Columns: []string{"u.id", "u.name", "o.id", "o.amount"},
+		OrderBy: []types.OrderBy{types.Asc("u.id"), types.Asc("o.id")},
+	})
+	if err != nil {
+		t.Fatalf("联表查询失败: %v", err)
+	}
+	if rows.Count() != 3 {
+		t.Fatalf("联表结果数量错误: %v", rows.All())
+	}
+	rows.Next()
+	if rows.GetInt("u.id") != 1 || rows.GetInt("o.id") != 1 || rows.GetInt("o.amount") != 50 {
+		t.Fatalf("联表结果错误: %v", rows.All())
+	}
+	rows.Next()
+	rows.Next()
+	if rows.GetString("u.name") != "Jerry" || rows.Get("o.id") != nil {
+		t.Fatalf("左连接结果错误: %v", rows.All())
+	}
+}
+
+// 子查询测试
+func TestSQLiteDriver_SubQuery(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)",
+		"CREATE TABLE banned (user_id INT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT)",
+		"INSERT INTO user (name) VALUES ('Tom'), ('Jerry'), ('Alice')",
+		"INSERT INTO banned (user_id) VALUES (2)",
+		"INSERT INTO orders (user_id) VALUES (1), (2)",
+	} {
+		if _, err = db.Exec(dbhelper.Cond().Raw(stmt).Build()); err != nil {
+			t.Fatalf("初始化失败: %v", err)
+		}
+	}
+
+	banned := types.Sub("banned", nil, &types.QueryOptions{Columns: []string{"user_id"}})
+	rows, err := db.Query("user", dbhelper.Cond().NotIn("id", banned).Build())
+	if err != nil || rows.Count() != 2 {
+		t.Fatalf("NOT IN 子查询错误: %v %v", rows, err)
+	}
+
+	hasOrder := types.Sub("orders", dbhelper.Cond().Eq("orders.user_id", types.Col("user.id")).Build())
+	rows, err = db.Query("user", dbhelper.Cond().Exists(hasOrder).NotIn("id", banned).Build())
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("EXISTS 子查询错误: %v %v", rows, err)
+	}
+	rows.Next()
+	if rows.GetString("name") != "Tom" {
+		t.Fatalf("EXISTS 子查询结果错误: %v", rows.All())
+	}
+
+	n, err := db.Count("user", dbhelper.Cond().NotExists(hasOrder).Build())
+	if err != nil || n != 1 {
+		t.Fatalf("NOT EXISTS 子查询错误: %d %v", n, err)
+	}
+}
+
+// 空值与取反条件测试
+func TestSQLiteDriver_NullAndNegation(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INT, email TEXT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	for _, u := range []struct {
+		name  string
+		age   int
+		email interface{}
+	}{
+		{"Tom", 18, "tom@example.com"},
+		{"Jerry", 25, nil},
+		{"test_user", 30, nil},
+		{"Alice", 40, "alice@example.com"},
+	} {
+		if _, err = db.Insert("user", dbhelper.Cond().Eq("name", u.name).Eq("age", u.age).Eq("email", u.email).Build()); err != nil {
+			t.Fatalf("插入失败: %v", err)
+		}
+	}
+
+	count := func(cond *types.ConditionExpr) int64 {
+		n, err := db.Count("user", cond)
+		if err != nil {
+			t.Fatalf("计数失败: %v", err)
+		}
+		return n
+	}
+	if n := count(dbhelper.Cond().Eq("email", nil).Build()); n != 2 {
+		t.Fatalf("Eq nil 应匹配 NULL: %d", n)
+	}
+	if n := count(dbhelper.Cond().IsNotNull("email").Build()); n != 2 {
+		t.Fatalf("IS NOT NULL 错误: %d", n)
+	}
+	if n := count(dbhelper.Cond().Between("age", 18, 30).NotLike("name", "test%").Build()); n != 2 {
+		t.Fatalf("BETWEEN/NOT LIKE 错误: %d", n)
+	}
+	if n := count(dbhelper.Cond().NotIn("name", []string{"Tom", "Jerry"}).Build()); n != 2 {
+		t.Fatalf("NOT IN 错误: %d", n)
+	}
+	if n := count(dbhelper.Cond().Not(dbhelper.Cond().IsNull("email"), dbhelper.Cond().Gt("age", 20)).Build()); n != 2 {
+		t.Fatalf("NOT 错误: %d", n)
+	}
+}
+
+// 原始条件参数绑定测试
+func TestSQLiteDriver_RawArgs(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec(dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, email TEXT)").Build()); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	n, err := db.Exec(dbhelper.Cond().Raw("INSERT INTO user (name, email) VALUES (?, ?), (?, ?)", "Tom", "Tom@Example.com", "Jerry", "jerry@example.com").Build())
+	if err != nil || n != 2 {
+		t.Fatalf("参数化Exec失败: %d %v", n, err)
+	}
+
+	rows, err := db.Query("user", dbhelper.Cond().Raw("LOWER(email) = ?", "tom@example.com").Build())
+	if err != nil || rows.Count() != 1 {
+		t.Fatalf("原始条件查询错误: %v %v", rows, err)
+	}
+	rows.Next()
+	if rows.GetString("name") != "Tom" {
+		t.Fatalf("原始条件查询结果错误: %v", rows.All())
+	}
+
+	// 参数不会被当作 SQL 执行
+	rows, err = db.Query("user", dbhelper.Cond().Raw("name = :name", sql.Named("name", "x' OR '1'='1")).Build())
+	if err != nil || rows.Count() != 0 {
+		t.Fatalf("命名参数查询错误: %v %v", rows, err)
+	}
+}
+
+// 原始查询测试
+func TestSQLiteDriver_QueryRaw(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec(dbhelper.Cond().Raw("CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INT, amount INT)").Build()); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	if _, err = db.Exec(dbhelper.Cond().Raw("INSERT INTO orders (user_id, amount) VALUES (1, 50), (1, 80), (2, 30)").Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+
+	rows, err := db.QueryRaw(`WITH big AS (SELECT * FROM orders WHERE amount > ?)
+		SELECT user_id, amount, SUM(amount) OVER (PARTITION BY user_id ORDER BY id) AS running FROM big ORDER BY id`, 10)
+	if err != nil {
+		t.Fatalf("原始查询失败: %v", err)
+	}
+	if rows.Count() != 3 {
+		t.Fatalf("原始查询结果数量错误: %v", rows.All())
+	}
+	rows.Next()
+	rows.Next()
+	if rows.GetInt("running") != 130 {
+		t.Fatalf("窗口函数结果错误: %v", rows.All())
+	}
+
+	cur, err := db.QueryRawIter("SELECT id FROM orders WHERE user_id = :uid UNION SELECT :extra", sql.Named("uid", 1), sql.Named("extra", 99))
+	if err != nil {
+		t.Fatalf("流式原始查询失败: %v", err)
+	}
+	var ids []int
+	for c, err := range cur.Iter() {
+		if err != nil {
+			t.Fatalf("流式原始查询遍历失败: %v", err)
+		}
+		ids = append(ids, c.GetInt("id"))
+	}
+	if len(ids) != 3 {
+		t.Fatalf("流式原始查询结果错误: %v", ids)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	rows, err = tx.QueryRaw("SELECT COUNT(*) AS n FROM orders")
+	if err != nil {
+		t.Fatalf("事务原始查询失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("n") != 3 {
+		t.Fatalf("事务原始查询结果错误: %v", rows.All())
+	}
+}
+
+// 表达式值测试
+func TestSQLiteDriver_Expr(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE counter (id INTEGER PRIMARY KEY AUTOINCREMENT, hits INT, max_hits INT, note TEXT, updated_at TEXT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	for _, c := range [][2]int{{1, 10}, {10, 10}} {
+		if _, err = db.Insert("counter", dbhelper.Cond().Eq("hits", c[0]).Eq("max_hits", c[1]).Eq("note", nil).Build()); err != nil {
+			t.Fatalf("插入失败: %v", err)
+		}
+	}
+
+	n, err := db.Update("counter",
+		dbhelper.Cond().Lt("hits", types.Col("max_hits")).Build(),
+		dbhelper.Cond().
+			Eq("hits", types.Col("hits").Add(1)).
+			Eq("note", types.Fn("COALESCE", types.Col("note"), "bumped")).
+			Eq("updated_at", types.Fn("NOW")).
+			Build())
+	if err != nil || n != 1 {
+		t.Fatalf("表达式更新错误: %d %v", n, err)
+	}
+
+	rows, err := db.Query("counter", dbhelper.Cond().Eq("id", 1).Build())
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("hits") != 2 || rows.GetString("note") != "bumped" || rows.GetString("updated_at") == "" {
+		t.Fatalf("表达式更新结果错误: %v", rows.All())
+	}
+
+	// 取模运算与原始片段中的 % 不能被当作表名模板的格式化指令
+	if _, err = db.Update("counter", dbhelper.Cond().Eq("id", 2).Build(), dbhelper.Cond().Eq("hits", types.Arith(types.Col("hits"), "%", 3)).Build()); err != nil {
+		t.Fatalf("取模更新失败: %v", err)
+	}
+	if n, err = db.Update("counter", dbhelper.Cond().Raw("note LIKE '%ump%'").Build(), dbhelper.Cond().Eq("max_hits", 5).Build()); err != nil || n != 1 {
+		t.Fatalf("LIKE 更新失败: %d %v", n, err)
+	}
+	rows, err = db.Query("counter", dbhelper.Cond().Raw("note LIKE '%ump%' OR hits % 2 = 1").Build(), &types.QueryOptions{OrderBy: []types.OrderBy{types.Asc("id")}})
+	if err != nil || rows.Count() != 2 {
+		t.Fatalf("含 %% 的查询失败: %v", err)
+	}
+	rows.Next()
+	rows.Next()
+	if rows.GetInt("hits") != 1 {
+		t.Fatalf("取模结果错误: %v", rows.All())
+	}
+	if n, err = db.Delete("counter", dbhelper.Cond().Raw("note LIKE '%ump%'").Build()); err != nil || n != 1 {
+		t.Fatalf("LIKE 删除失败: %d %v", n, err)
+	}
+	if n, err = db.Exec(dbhelper.Cond().Raw("UPDATE counter SET note = ? WHERE hits % 2 = 1", "100%").Build()); err != nil || n != 1 {
+		t.Fatalf("含 %% 的原始语句失败: %d %v", n, err)
+	}
+}
+
+// 更新操作符测试
+func TestSQLiteDriver_UpdateOperators(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	createTable := dbhelper.Cond().Raw("CREATE TABLE item (id INTEGER PRIMARY KEY AUTOINCREMENT, views INT, stock INT, price REAL, nickname TEXT, note TEXT, tags TEXT)").Build()
+	if _, err = db.Exec(createTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	if _, err = db.Insert("item", dbhelper.Cond().Eq("views", 10).Eq("stock", 5).Eq("price", 100.0).Eq("nickname", "old").Eq("note", "x").Eq("tags", nil).Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+
+	set := dbhelper.Cond().Inc("views", 1).Dec("stock", 2).Mul("price", 0.5).SetNull("note").SetIfNull("nickname", "new").Push("tags", "a").Build()
+	if _, err = db.Update("item", dbhelper.Cond().Eq("id", 1).Build(), set); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if _, err = db.Update("item", dbhelper.Cond().Eq("id", 1).Build(), dbhelper.Cond().Push("tags", map[string]int{"b": 1}).Build()); err != nil {
+		t.Fatalf("追加失败: %v", err)
+	}
+
+	rows, err := db.Query("item", dbhelper.Cond().Eq("id", 1).Build())
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	rows.Next()
+	if rows.GetInt("views") != 11 || rows.GetInt("stock") != 3 || rows.Get("price") != 50.0 ||
+		rows.Get("note") != nil || rows.GetString("nickname") != "old" || rows.GetString("tags") != `["a",{"b":1}]` {
+		t.Fatalf("更新操作符结果错误: %v", rows.All())
+	}
+}
+
+// 保存点与嵌套事务测试
+func TestSQLiteDriver_Savepoint(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec(dbhelper.Cond().Raw("CREATE TABLE user (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)").Build()); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	insert := func(tx types.Tx, name string) {
+		data, _ := types.FromMap(map[string]interface{}{"name": name})
+		if _, err := tx.Insert("user", data); err != nil {
+			t.Fatalf("插入失败: %v", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	insert(tx, "Tom")
+
+	// 嵌套事务回滚不影响外层
+	inner, err := tx.Begin()
+	if err != nil {
+		t.Fatalf("开启嵌套事务失败: %v", err)
+	}
+	insert(inner, "Jerry")
+	if err = inner.Rollback(); err != nil {
+		t.Fatalf("嵌套事务回滚失败: %v", err)
+	}
+	if err = inner.Commit(); err != sql.ErrTxDone {
+		t.Fatalf("重复结束嵌套事务应返回 ErrTxDone: %v", err)
+	}
+
+	// 嵌套事务提交后随外层提交
+	inner, err = tx.Begin()
+	if err != nil {
+		t.Fatalf("开启嵌套事务失败: %v", err)
+	}
+	insert(inner, "Alice")
+	deeper, err := inner.Begin()
+	if err != nil {
+		t.Fatalf("开启多层嵌套事务失败: %v", err)
+	}
+	insert(deeper, "Bob")
+	if err = deeper.Commit(); err != nil {
+		t.Fatalf("多层嵌套事务提交失败: %v", err)
+	}
+	if err = inner.Commit(); err != nil {
+		t.Fatalf("嵌套事务提交失败: %v", err)
+	}
+
+	// 手动保存点
+	if err = tx.Savepoint("before_eve"); err != nil {
+		t.Fatalf("创建保存点失败: %v", err)
+	}
+	insert(tx, "Eve")
+	if err = tx.RollbackTo("before_eve"); err != nil {
+		t.Fatalf("回滚到保存点失败: %v", err)
+	}
+	if err = tx.Release("before_eve"); err != nil {
+		t.Fatalf("释放保存点失败: %v", err)
+	}
+	if err = tx.Savepoint("x; DROP TABLE user"); err == nil {
+		t.Fatalf("非法保存点名称应返回错误")
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("提交失败: %v", err)
+	}
+
+	rows, err := db.Query("user", nil, &types.QueryOptions{Columns: []string{"name"}, OrderBy: []types.OrderBy{types.Asc("id")}})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		names = append(names, rows.GetString("name"))
+	}
+	if strings.Join(names, ",") != "Tom,Alice,Bob" {
+		t.Fatalf("保存点结果错误: %v", names)
+	}
+}
+
+// 事务辅助函数测试
+func TestWithTx(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    "file:withtx?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec(dbhelper.Cond().Raw("CREATE TABLE account (id INTEGER PRIMARY KEY, balance INT)").Build()); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	if _, err = db.Exec(dbhelper.Cond().Raw("INSERT INTO account (id, balance) VALUES (1, 100)").Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+	balance := func() int {
+		rows, err := db.Query("account", dbhelper.Cond().Eq("id", 1).Build())
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		rows.Next()
+		return rows.GetInt("balance")
+	}
+	withdraw := func(tx types.Tx) error {
+		_, err := tx.Update("account", dbhelper.Cond().Eq("id", 1).Build(), dbhelper.Cond().Dec("balance", 10).Build())
+		return err
+	}
+
+	// 成功时提交
+	if err = dbhelper.WithTx(db, nil, withdraw); err != nil || balance() != 90 {
+		t.Fatalf("提交失败: %v %d", err, balance())
+	}
+
+	// 返回错误时回滚
+	errFail := errors.New("fail")
+	err = dbhelper.WithTx(db, nil, func(tx types.Tx) error {
+		if err := withdraw(tx); err != nil {
+			return err
+		}
+		return errFail
+	})
+	if err != errFail || balance() != 90 {
+		t.Fatalf("错误回滚失败: %v %d", err, balance())
+	}
+
+	// panic 时回滚并继续抛出
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("panic 应继续抛出: %v", p)
+			}
+		}()
+		dbhelper.WithTx(db, nil, func(tx types.Tx) error {
+			withdraw(tx)
+			panic("boom")
+		})
+	}()
+	if balance() != 90 {
+		t.Fatalf("panic 回滚失败: %d", balance())
+	}
+
+	// 可重试的错误按次数重试
+	attempts := 0
+	opts := &dbhelper.TxOptions{MaxRetries: 2, Backoff: time.Millisecond, RetryIf: func(err error) bool { return err == errFail }}
+	err = dbhelper.WithTx(db, opts, func(tx types.Tx) error {
+		attempts++
+		if err := withdraw(tx); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 || balance() != 80 {
+		t.Fatalf("重试失败: %v %d %d", err, attempts, balance())
+	}
+
+	// 只读事务
+	err = dbhelper.WithTx(db, &dbhelper.TxOptions{ReadOnly: true, MaxRetries: -1}, func(tx types.Tx) error {
+		_, err := tx.Query("account", nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("只读事务失败: %v", err)
+	}
+
+	// SQLite 锁冲突被识别为可重试
+	if !dbhelper.IsRetryable(sqlite3.Error{Code: sqlite3.ErrBusy}) || dbhelper.IsRetryable(errFail) {
+		t.Fatalf("可重试错误识别错误")
+	}
+}
+
+// 连接生命周期测试
+func TestSQLiteDriver_Lifecycle(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver:          sqlite.DriverName,
+		DSN:             ":memory:",
+		MaxOpen:         2,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Second,
+		ConnectTimeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err = db.Ping(); err != nil {
+		t.Fatalf("Ping 失败: %v", err)
+	}
+	if stats := db.Stats(); stats.MaxOpenConnections != 2 || stats.OpenConnections == 0 {
+		t.Fatalf("连接池统计错误: %+v", stats)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+	if err = db.Ping(); err == nil {
+		t.Fatalf("关闭后 Ping 应返回错误")
+	}
+
+	// Open 时检查连通性
+	_, err = dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    "file:/nonexistent/dir/test.db?mode=ro",
+	})
+	if err == nil {
+		t.Fatalf("无法连接时 Open 应返回错误")
+	}
+}
+
+func TestSQLiteDriver_Errors(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver:  sqlite.DriverName,
+		DSN:     "file::memory:?_foreign_keys=1",
+		MaxOpen: 1,
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	for _, ddl := range []string{
+		"CREATE TABLE team (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE member (id INTEGER PRIMARY KEY, email TEXT UNIQUE, team_id INT REFERENCES team(id))",
+	} {
+		if _, err = db.Exec(dbhelper.Cond().Raw(ddl).Build()); err != nil {
+			t.Fatalf("建表失败: %v", err)
+		}
+	}
+	if _, err = db.Insert("team", dbhelper.Cond().Eq("id", 1).Eq("name", "dev").Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+	if _, err = db.Insert("member", dbhelper.Cond().Eq("email", "a@example.com").Eq("team_id", 1).Build()); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+
+	// 唯一约束：保留表名、约束与 SQL，且仍可取得原始驱动错误
+	_, err = db.Insert("member", dbhelper.Cond().Eq("email", "a@example.com").Eq("team_id", 1).Build())
+	if !errors.Is(err, types.ErrUniqueViolation) {
+		t.Fatalf("期望 ErrUniqueViolation, 实际: %v", err)
+	}
+	var de *types.DBError
+	if !errors.As(err, &de) || de.Table != "member" || de.Constraint != "member.email" || !strings.HasPrefix(de.SQL, "INSERT INTO") {
+		t.Fatalf("错误信息不完整: %+v", de)
+	}
+	var se sqlite3.Error
+	if !errors.As(err, &se) || se.Code != sqlite3.ErrConstraint {
+		t.Fatalf("应能取得原始驱动错误: %v", err)
+	}
+
+	// 主键冲突同样归为唯一约束
+	if _, err = db.Insert("team", dbhelper.Cond().Eq("id", 1).Eq("name", "ops").Build()); !errors.Is(err, types.ErrUniqueViolation) {
+		t.Fatalf("主键冲突期望 ErrUniqueViolation, 实际: %v", err)
+	}
+
+	_, err = db.Insert("member", dbhelper.Cond().Eq("email", "b@example.com").Eq("team_id", 99).Build())
+	if !errors.Is(err, types.ErrForeignKeyViolation) {
+		t.Fatalf("期望 ErrForeignKeyViolation, 实际: %v", err)
+	}
+
+	_, err = db.Update("team", dbhelper.Cond().Eq("id", 1).Build(), dbhelper.Cond().SetNull("name").Build())
+	if !errors.Is(err, types.ErrNotNullViolation) || !errors.As(err, &de) || de.Constraint != "team.name" {
+		t.Fatalf("期望 ErrNotNullViolation, 实际: %v", err)
+	}
+
+	// 事务内的错误同样被分类；非约束错误原样返回
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	if _, err = tx.Insert("team", dbhelper.Cond().Eq("id", 1).Eq("name", "ops").Build()); !errors.Is(err, types.ErrUniqueViolation) {
+		t.Fatalf("事务内期望 ErrUniqueViolation, 实际: %v", err)
+	}
+	tx.Rollback()
+	if _, err = db.Query("missing", nil); err == nil || errors.As(err, &de) {
+		t.Fatalf("表不存在不应被分类: %v", err)
+	}
+
+	rows, err := db.Query("team", dbhelper.Cond().Eq("id", 2).Build())
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if _, err = types.ScanOne[struct{ ID int }](rows); !errors.Is(err, types.ErrNotFound) {
+		t.Fatalf("期望 ErrNotFound, 实际: %v", err)
+	}
+}
+
+func TestSQLiteDriver_Inspector(t *testing.T) {
+	db, err := dbhelper.Open(types.DBConfig{
+		Driver: sqlite.DriverName,
+		DSN:    ":memory:",
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	for _, ddl := range []string{
+		"CREATE TABLE team (id INTEGER PRIMARY KEY, name VARCHAR(50) NOT NULL DEFAULT 'none')",
+		"CREATE TABLE member (org TEXT, email TEXT, team_id INT REFERENCES team ON DELETE CASCADE, PRIMARY KEY (org, email))",
+		"CREATE UNIQUE INDEX idx_member_team ON member (team_id, email)",
+	} {
+		if _, err = db.Exec(dbhelper.Cond().Raw(ddl).Build()); err != nil {
+			t.Fatalf("建表失败: %v", err)
+		}
+	}
+
+	tables, err := db.ListTables()
+	if err != nil || strings.Join(tables, ",") != "member,team" {
+		t.Fatalf("表列表错误: %v %v", tables, err)
+	}
+
+	cols, err := db.Columns("team")
+	if err != nil || len(cols) != 2 {
+		t.Fatalf("读取列失败: %+v %v", cols, err)
+	}
+	if !cols[0].PrimaryKey || !cols[0].AutoIncrement || cols[0].Type != "INTEGER" {
+		t.Fatalf("主键列信息错误: %+v", cols[0])
+	}
+	if cols[1].Nullable || cols[1].Default == nil || *cols[1].Default != "'none'" || cols[1].Type != "VARCHAR(50)" {
+		t.Fatalf("name 列信息错误: %+v", cols[1])
+	}
+	if _, err = db.Columns("missing"); !errors.Is(err, types.ErrNotFound) {
+		t.Fatalf("表不存在期望 ErrNotFound, 实际: %v", err)
+	}
+
+	// 复合主键：按主键顺序返回，不是 rowid 别名
+	pk, err := db.PrimaryKey("member")
+	if err != nil || strings.Join(pk, ",") != "org,email" {
+		t.Fatalf("主键错误: %v %v", pk, err)
+	}
+	cols, err = db.Columns("member")
+	if err != nil || cols[0].AutoIncrement || !cols[1].PrimaryKey || cols[2].PrimaryKey {
+		t.Fatalf("复合主键列信息错误: %+v %v", cols, err)
+	}
+
+	indexes, err := db.Indexes("member")
+	if err != nil || len(indexes) != 2 {
+		t.Fatalf("读取索引失败: %+v %v", indexes, err)
+	}
+	if indexes[0].Name != "idx_member_team" || !indexes[0].Unique || indexes[0].Primary || strings.Join(indexes[0].Columns, ",") != "team_id,email" {
+		t.Fatalf("唯一索引信息错误: %+v", indexes[0])
+	}
+	if !indexes[1].Primary || strings.Join(indexes[1].Columns, ",") != "org,email" {
+		t.Fatalf("主键索引信息错误: %+v", indexes[1])
+	}
+
+	// 未写引用列时解析为被引用表的主键
+	fks, err := db.ForeignKeys("member")
+	if err != nil || len(fks) != 1 {
+		t.Fatalf("读取外键失败: %+v %v", fks, err)
+	}
+	fk := fks[0]
+	if fk.RefTable != "team" || strings.Join(fk.Columns, ",") != "team_id" || strings.Join(fk.RefColumns, ",") != "id" || fk.OnDelete != "CASCADE" || fk.OnUpdate != "NO ACTION" {
+		t.Fatalf("外键信息错误: %+v", fk)
+	}
+
+	// 事务内同样可用
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(dbhelper.Cond().Raw("CREATE TABLE audit (id INTEGER PRIMARY KEY)").Build()); err != nil {
+		t.Fatalf("事务内建表失败: %v", err)
+	}
+	if tables, err = tx.ListTables(); err != nil || len(tables) != 3 {
+		t.Fatalf("事务内表列表错误: %v %v", tables, err)
+	}
+}
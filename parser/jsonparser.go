@@ -3,6 +3,8 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/Kaguya154/dbhelper/dbtools"
@@ -48,6 +50,26 @@ func putSlice(s []interface{}) {
 }
 
 func (p *JsonParser) Parse(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr) (string, []interface{}, error) {
+	return p.parse(op, where, set, nil)
+}
+
+// ParseReturning 与 Parse 相同，并在结果中附加 returning 列
+func (p *JsonParser) ParseReturning(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr, returning []string) (string, []interface{}, error) {
+	if op != types.OpInsert && op != types.OpUpdate && op != types.OpDelete {
+		return "", nil, fmt.Errorf("RETURNING only supports Insert, Update and Delete")
+	}
+	if len(returning) == 0 {
+		return "", nil, fmt.Errorf("RETURNING columns cannot be empty")
+	}
+	return p.parse(op, where, set, returning)
+}
+
+// ParseReturningAndCache JSON 结果包含参数值，不做缓存
+func (p *JsonParser) ParseReturningAndCache(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr, returning []string) (string, []interface{}, error) {
+	return p.ParseReturning(op, where, set, returning)
+}
+
+func (p *JsonParser) parse(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr, returning []string) (string, []interface{}, error) {
 	result := getMap()
 	defer putMap(result)
 	result["op"] = opNameMap[op]
@@ -76,7 +98,11 @@ func (p *JsonParser) Parse(op types.OpType, where *types.ConditionExpr, set *typ
 			if set == nil {
 				return "", nil, fmt.Errorf("Update data cannot be empty")
 			}
-			result["update"] = buildJsonUpdateOpt(set)
+			update, err := buildJsonUpdateOpt(set)
+			if err != nil {
+				return "", nil, err
+			}
+			result["update"] = update
 		}
 
 	case types.OpExec:
@@ -92,6 +118,9 @@ func (p *JsonParser) Parse(op types.OpType, where *types.ConditionExpr, set *typ
 	default:
 		return "", nil, fmt.Errorf("unsupported op: %d", op)
 	}
+	if len(returning) > 0 {
+		result["returning"] = returning
+	}
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
@@ -102,18 +131,275 @@ func (p *JsonParser) Parse(op types.OpType, where *types.ConditionExpr, set *typ
 }
 
 func (p *JsonParser) ParseAndCache(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr) (string, []interface{}, error) {
-	if sqlStr, args, ok := dbtools.GetCondCache(p.DriverID, op, where); ok {
-		return sqlStr, args, nil
+	// JSON 结果直接包含参数值，缓存键需要带上值
+	key := dbtools.MakeCondValueCacheKey(p.DriverID, op, where, set)
+	if jsonStr, ok := dbtools.GetCondCache(key); ok {
+		return jsonStr, nil, nil
+	}
+	jsonStr, args, err := p.Parse(op, where, set)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, jsonStr)
+	return jsonStr, args, nil
+}
+
+// ParseInsertMany 生成多行插入 JSON，data 为文档数组
+func (p *JsonParser) ParseInsertMany(rows []*types.ConditionExpr) (string, []interface{}, error) {
+	if len(rows) == 0 {
+		return "", nil, fmt.Errorf("InsertMany rows cannot be empty")
+	}
+	docs := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if row == nil || row.Op != types.OpAnd || len(row.Exprs) == 0 {
+			return "", nil, fmt.Errorf("Insert data must be AND expr with fields")
+		}
+		doc := make(map[string]interface{}, len(row.Exprs))
+		for _, expr := range row.Exprs {
+			if expr.Op != types.OpEq {
+				return "", nil, fmt.Errorf("Insert only supports EQ expr")
+			}
+			doc[expr.Field] = expr.Value
+		}
+		docs = append(docs, doc)
+	}
+	jsonBytes, err := json.Marshal(map[string]interface{}{
+		"op":   opNameMap[types.OpInsert],
+		"data": docs,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return string(jsonBytes), nil, nil
+}
+
+// ParseInsertManyAndCache 插入数据各不相同，JSON 结果不做缓存
+func (p *JsonParser) ParseInsertManyAndCache(rows []*types.ConditionExpr) (string, []interface{}, error) {
+	return p.ParseInsertMany(rows)
+}
+
+// ParseUpsert 生成 upsert 更新 JSON：冲突列作为 filter，UpdateColumns 放入 $set，
+// 其余列放入 $setOnInsert，并带有 upsert: true 标记
+func (p *JsonParser) ParseUpsert(data *types.ConditionExpr, opts *types.UpsertOptions) (string, []interface{}, error) {
+	if data == nil || data.Op != types.OpAnd || len(data.Exprs) == 0 {
+		return "", nil, fmt.Errorf("Insert data must be AND expr with fields")
+	}
+	if opts == nil || (!opts.DoNothing && len(opts.UpdateColumns) == 0) {
+		return "", nil, fmt.Errorf("Upsert requires UpdateColumns or DoNothing")
+	}
+	if len(opts.ConflictColumns) == 0 {
+		return "", nil, fmt.Errorf("Upsert requires ConflictColumns")
+	}
+	values := make(map[string]interface{}, len(data.Exprs))
+	for _, expr := range data.Exprs {
+		if expr.Op != types.OpEq {
+			return "", nil, fmt.Errorf("Insert only supports EQ expr")
+		}
+		values[expr.Field] = expr.Value
 	}
-	sqlStr, args, err := p.Parse(op, where, set)
+	filter := make(map[string]interface{}, len(opts.ConflictColumns))
+	for _, col := range opts.ConflictColumns {
+		v, ok := values[col]
+		if !ok {
+			return "", nil, fmt.Errorf("Upsert conflict column %s not in data", col)
+		}
+		filter[col] = v
+	}
+	set := make(map[string]interface{})
+	if !opts.DoNothing {
+		for _, col := range opts.UpdateColumns {
+			v, ok := values[col]
+			if !ok {
+				return "", nil, fmt.Errorf("Upsert update column %s not in data", col)
+			}
+			set[col] = v
+		}
+	}
+	onInsert := make(map[string]interface{})
+	for col, v := range values {
+		if _, ok := filter[col]; ok {
+			continue
+		}
+		if _, ok := set[col]; ok {
+			continue
+		}
+		onInsert[col] = v
+	}
+	update := make(map[string]interface{}, 2)
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(onInsert) > 0 {
+		update["$setOnInsert"] = onInsert
+	}
+	jsonBytes, err := json.Marshal(map[string]interface{}{
+		"op":     opNameMap[types.OpUpdate],
+		"filter": filter,
+		"update": update,
+		"upsert": true,
+	})
 	if err != nil {
 		return "", nil, err
 	}
-	dbtools.SetCondCache(p.DriverID, op, where, sqlStr, args)
-	return sqlStr, args, nil
+	return string(jsonBytes), nil, nil
+}
+
+// ParseUpsertAndCache 插入数据各不相同，JSON 结果不做缓存
+func (p *JsonParser) ParseUpsertAndCache(data *types.ConditionExpr, opts *types.UpsertOptions) (string, []interface{}, error) {
+	return p.ParseUpsert(data, opts)
 }
 
-// 优化递归构建，尽量复用 slice/map
+// ParseQuery 生成带查询选项的查询 JSON，列投影、排序与分页分别映射为 projection/sort/skip/limit
+func (p *JsonParser) ParseQuery(where *types.ConditionExpr, opts *types.QueryOptions) (string, []interface{}, error) {
+	if opts != nil && len(opts.Joins) > 0 {
+		return "", nil, fmt.Errorf("Join is not supported by JsonParser")
+	}
+	if opts != nil && (opts.Lock != "" || opts.LockWait != "") {
+		return "", nil, fmt.Errorf("row locking is not supported by JsonParser")
+	}
+	if opts != nil && (len(opts.Aggregates) > 0 || len(opts.GroupBy) > 0) {
+		return buildJsonAggregate(where, opts)
+	}
+	result := getMap()
+	defer putMap(result)
+	result["op"] = opNameMap[types.OpQuery]
+	if where != nil {
+		result["filter"] = buildJsonFilterOpt(where)
+	}
+	if opts != nil {
+		if opts.Limit < 0 || opts.Offset < 0 {
+			return "", nil, fmt.Errorf("Limit and Offset cannot be negative")
+		}
+		if len(opts.Columns) > 0 {
+			projection := make(map[string]interface{}, len(opts.Columns))
+			for _, col := range opts.Columns {
+				projection[col] = 1
+			}
+			result["projection"] = projection
+		}
+		if opts.Distinct {
+			result["distinct"] = true
+		}
+		if len(opts.OrderBy) > 0 {
+			// 使用数组保持多列排序的先后顺序
+			sort := make([]interface{}, 0, len(opts.OrderBy))
+			for _, o := range opts.OrderBy {
+				dir := 1
+				if o.Desc {
+					dir = -1
+				}
+				sort = append(sort, map[string]interface{}{o.Field: dir})
+			}
+			result["sort"] = sort
+		}
+		if opts.Offset > 0 {
+			result["skip"] = opts.Offset
+		}
+		if opts.Limit > 0 {
+			result["limit"] = opts.Limit
+		}
+	}
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(jsonBytes), nil, nil
+}
+
+func (p *JsonParser) ParseQueryAndCache(where *types.ConditionExpr, opts *types.QueryOptions) (string, []interface{}, error) {
+	key := dbtools.MakeQueryValueCacheKey(p.DriverID, where, opts)
+	if jsonStr, ok := dbtools.GetCondCache(key); ok {
+		return jsonStr, nil, nil
+	}
+	jsonStr, args, err := p.ParseQuery(where, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, jsonStr)
+	return jsonStr, args, nil
+}
+
+var jsonAggregateOps = map[types.AggregateFunc]string{
+	types.AggSum: "$sum",
+	types.AggAvg: "$avg",
+	types.AggMin: "$min",
+	types.AggMax: "$max",
+}
+
+// buildJsonAggregate 将聚合查询映射为聚合管道：$match -> $group -> $match(having) -> $sort -> $skip -> $limit
+func buildJsonAggregate(where *types.ConditionExpr, opts *types.QueryOptions) (string, []interface{}, error) {
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return "", nil, fmt.Errorf("Limit and Offset cannot be negative")
+	}
+	pipeline := make([]interface{}, 0, 6)
+	if where != nil {
+		pipeline = append(pipeline, map[string]interface{}{"$match": buildJsonFilterOpt(where)})
+	}
+
+	var id interface{}
+	if len(opts.GroupBy) > 0 {
+		keys := make(map[string]interface{}, len(opts.GroupBy))
+		for _, col := range opts.GroupBy {
+			keys[col] = "$" + col
+		}
+		id = keys
+	}
+	group := map[string]interface{}{"_id": id}
+	for _, a := range opts.Aggregates {
+		if a.Alias == "" {
+			return "", nil, fmt.Errorf("aggregate %s requires an alias", a.Func)
+		}
+		if a.Distinct {
+			return "", nil, fmt.Errorf("distinct aggregate is not supported by JsonParser")
+		}
+		if a.Func == types.AggCount {
+			group[a.Alias] = map[string]interface{}{"$sum": 1}
+			continue
+		}
+		op, ok := jsonAggregateOps[a.Func]
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported aggregate: %s", a.Func)
+		}
+		if a.Field == "" || a.Field == "*" {
+			return "", nil, fmt.Errorf("%s requires a field", a.Func)
+		}
+		group[a.Alias] = map[string]interface{}{op: "$" + a.Field}
+	}
+	pipeline = append(pipeline, map[string]interface{}{"$group": group})
+
+	if opts.Having != nil {
+		pipeline = append(pipeline, map[string]interface{}{"$match": buildJsonFilterOpt(opts.Having)})
+	}
+	if len(opts.OrderBy) > 0 {
+		sort := make([]interface{}, 0, len(opts.OrderBy))
+		for _, o := range opts.OrderBy {
+			dir := 1
+			if o.Desc {
+				dir = -1
+			}
+			sort = append(sort, map[string]interface{}{o.Field: dir})
+		}
+		pipeline = append(pipeline, map[string]interface{}{"$sort": sort})
+	}
+	if opts.Offset > 0 {
+		pipeline = append(pipeline, map[string]interface{}{"$skip": opts.Offset})
+	}
+	if opts.Limit > 0 {
+		pipeline = append(pipeline, map[string]interface{}{"$limit": opts.Limit})
+	}
+
+	jsonBytes, err := json.Marshal(map[string]interface{}{
+		"op":       "aggregate",
+		"pipeline": pipeline,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return string(jsonBytes), nil, nil
+}
+
+// 优化递归构建，尽量复用 slice；返回给调用方的 map 不能放回池中
 func buildJsonFilterOpt(cond *types.ConditionExpr) interface{} {
 	if cond == nil {
 		return nil
@@ -129,50 +415,79 @@ func buildJsonFilterOpt(cond *types.ConditionExpr) interface{} {
 		if cond.Op == types.OpOr {
 			opName = "$or"
 		}
-		m := getMap()
-		defer putMap(m)
+		m := make(map[string]interface{}, 1)
 		m[opName] = append([]interface{}(nil), arr...)
 		return m
 	case types.OpEq:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = cond.Value
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = jsonValue(cond.Value)
 		return m
 	case types.OpNe:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = map[string]interface{}{"$ne": cond.Value}
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$ne": jsonValue(cond.Value)}
 		return m
 	case types.OpGt:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = map[string]interface{}{"$gt": cond.Value}
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$gt": jsonValue(cond.Value)}
 		return m
 	case types.OpGte:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = map[string]interface{}{"$gte": cond.Value}
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$gte": jsonValue(cond.Value)}
 		return m
 	case types.OpLt:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = map[string]interface{}{"$lt": cond.Value}
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$lt": jsonValue(cond.Value)}
 		return m
 	case types.OpLte:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = map[string]interface{}{"$lte": cond.Value}
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$lte": jsonValue(cond.Value)}
 		return m
 	case types.OpLike:
-		m := getMap()
-		defer putMap(m)
+		m := make(map[string]interface{}, 1)
 		m[cond.Field] = map[string]interface{}{"$like": cond.Value}
 		return m
-	case types.OpIn:
-		m := getMap()
-		defer putMap(m)
-		m[cond.Field] = map[string]interface{}{"$in": cond.Values}
+	case types.OpNotLike:
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$not": map[string]interface{}{"$like": cond.Value}}
+		return m
+	case types.OpIsNull:
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = nil
 		return m
+	case types.OpNotNull:
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$exists": true, "$ne": nil}
+		return m
+	case types.OpBetween:
+		if len(cond.Values) != 2 {
+			return nil
+		}
+		m := make(map[string]interface{}, 1)
+		m[cond.Field] = map[string]interface{}{"$gte": jsonValue(cond.Values[0]), "$lte": jsonValue(cond.Values[1])}
+		return m
+	case types.OpNot:
+		if len(cond.Exprs) == 1 {
+			return map[string]interface{}{"$not": buildJsonFilterOpt(cond.Exprs[0])}
+		}
+		return map[string]interface{}{"$not": buildJsonFilterOpt(&types.ConditionExpr{Op: types.OpAnd, Exprs: cond.Exprs})}
+	case types.OpIn, types.OpNotIn:
+		opName := "$in"
+		if cond.Op == types.OpNotIn {
+			opName = "$nin"
+		}
+		m := make(map[string]interface{}, 1)
+		if q, ok := cond.Value.(*types.SubQuery); ok {
+			m[cond.Field] = map[string]interface{}{opName: buildJsonSubQuery(q)}
+		} else {
+			m[cond.Field] = map[string]interface{}{opName: cond.Values}
+		}
+		return m
+	case types.OpExists:
+		q, _ := cond.Value.(*types.SubQuery)
+		return map[string]interface{}{"$exists": buildJsonSubQuery(q)}
+	case types.OpNotExists:
+		q, _ := cond.Value.(*types.SubQuery)
+		return map[string]interface{}{"$not": map[string]interface{}{"$exists": buildJsonSubQuery(q)}}
 	case types.OpRaw:
 		if raw, ok := cond.Value.(map[string]interface{}); ok {
 			return raw
@@ -181,18 +496,119 @@ func buildJsonFilterOpt(cond *types.ConditionExpr) interface{} {
 	return nil
 }
 
-func buildJsonUpdateOpt(set *types.ConditionExpr) map[string]interface{} {
-	update := getMap()
-	defer putMap(update)
-	if set.Op == types.OpAnd && len(set.Exprs) > 0 {
-		for _, expr := range set.Exprs {
-			if expr.Op != types.OpEq {
-				continue
+var jsonArithOps = map[string]string{
+	"+": "$add",
+	"-": "$subtract",
+	"*": "$multiply",
+	"/": "$divide",
+	"%": "$mod",
+}
+
+// jsonValue 子查询转换为嵌套的查询文档；列引用转换为 "$列名"，
+// 算术与函数表达式转换为 {"$add": [...]}、{"$coalesce": [...]} 形式，其余值原样输出
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *types.SubQuery:
+		return buildJsonSubQuery(v)
+	case types.Column:
+		return "$" + string(v)
+	case *types.ArithExpr:
+		op, ok := jsonArithOps[v.Op]
+		if !ok {
+			op = v.Op
+		}
+		return map[string]interface{}{op: []interface{}{jsonValue(v.Left), jsonValue(v.Right)}}
+	case *types.FuncExpr:
+		args := make([]interface{}, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = jsonValue(arg)
+		}
+		return map[string]interface{}{"$" + strings.ToLower(v.Name): args}
+	}
+	return v
+}
+
+// buildJsonSubQuery 将子查询表示为 {"$query": {"from": ..., "filter": ..., "projection": ..., "limit": ...}}
+func buildJsonSubQuery(q *types.SubQuery) map[string]interface{} {
+	if q == nil {
+		return nil
+	}
+	sub := map[string]interface{}{"from": q.Table}
+	if q.Where != nil {
+		sub["filter"] = buildJsonFilterOpt(q.Where)
+	}
+	if opts := q.Options; opts != nil {
+		if len(opts.Columns) > 0 {
+			projection := make(map[string]interface{}, len(opts.Columns))
+			for _, col := range opts.Columns {
+				projection[col] = 1
 			}
-			update[expr.Field] = expr.Value
+			sub["projection"] = projection
+		}
+		if opts.Limit > 0 {
+			sub["limit"] = opts.Limit
 		}
-	} else if set.Op == types.OpEq {
-		update[set.Field] = set.Value
 	}
-	return map[string]interface{}{"$set": update}
+	return map[string]interface{}{"$query": sub}
+}
+
+func buildJsonUpdateOpt(set *types.ConditionExpr) (map[string]interface{}, error) {
+	exprs := set.Exprs
+	if set.Op != types.OpAnd {
+		exprs = []*types.ConditionExpr{set}
+	}
+	update := make(map[string]interface{}, 4)
+	group := func(op string) map[string]interface{} {
+		m, ok := update[op].(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{}, 4)
+			update[op] = m
+		}
+		return m
+	}
+	for _, expr := range exprs {
+		if expr.Field == "" {
+			return nil, fmt.Errorf("Update field cannot be empty")
+		}
+		switch expr.Op {
+		case types.OpEq:
+			group("$set")[expr.Field] = jsonValue(expr.Value)
+		case types.OpInc:
+			group("$inc")[expr.Field] = expr.Value
+		case types.OpDec:
+			n, err := negateNumber(expr.Value)
+			if err != nil {
+				return nil, err
+			}
+			group("$inc")[expr.Field] = n
+		case types.OpMul:
+			group("$mul")[expr.Field] = expr.Value
+		case types.OpSetNull:
+			group("$unset")[expr.Field] = ""
+		case types.OpSetIfNull:
+			group("$set")[expr.Field] = map[string]interface{}{"$ifNull": []interface{}{"$" + expr.Field, jsonValue(expr.Value)}}
+		case types.OpPush:
+			group("$push")[expr.Field] = expr.Value
+		default:
+			return nil, fmt.Errorf("Update does not support %s expr", expr.Op)
+		}
+	}
+	if len(update) == 0 {
+		return nil, fmt.Errorf("Invalid update data")
+	}
+	return update, nil
+}
+
+// negateNumber 将 Dec 的数值取反以映射为 $inc
+func negateNumber(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return -rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return -int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return -rv.Float(), nil
+	}
+	return nil, fmt.Errorf("Dec requires a number, got %T", v)
 }
@@ -70,3 +70,120 @@ func BenchmarkJsonParseComplexCond(b *testing.B) {
 		}
 	})
 }
+
+func TestJsonParser_QueryOptions(t *testing.T) {
+	p := &parser.JsonParser{DriverName: "json", DriverID: 1}
+	where := dbhelper.Cond().Eq("status", "active").Build()
+	opts := &types.QueryOptions{
+		Columns:  []string{"id", "name"},
+		Distinct: true,
+		OrderBy:  []types.OrderBy{types.Desc("age"), types.Asc("name")},
+		Limit:    10,
+		Offset:   20,
+	}
+	jsonStr, _, err := p.ParseQueryAndCache(where, opts)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := `{"distinct":true,"filter":{"status":"active"},"limit":10,"op":"query","projection":{"id":1,"name":1},"skip":20,"sort":[{"age":-1},{"name":1}]}`
+	if jsonStr != want {
+		t.Fatalf("查询JSON错误: %s", jsonStr)
+	}
+}
+
+func TestJsonParser_Upsert(t *testing.T) {
+	p := &parser.JsonParser{DriverName: "json", DriverID: 1}
+	data := dbhelper.Cond().Eq("email", "tom@example.com").Eq("name", "Tom").Eq("age", 20).Build()
+	jsonStr, _, err := p.ParseUpsertAndCache(data, &types.UpsertOptions{ConflictColumns: []string{"email"}, UpdateColumns: []string{"name"}})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := `{"filter":{"email":"tom@example.com"},"op":"update","update":{"$set":{"name":"Tom"},"$setOnInsert":{"age":20}},"upsert":true}`
+	if jsonStr != want {
+		t.Fatalf("upsert JSON错误: %s", jsonStr)
+	}
+	jsonStr, _, err = p.ParseUpsert(data, &types.UpsertOptions{ConflictColumns: []string{"email"}, DoNothing: true})
+	want = `{"filter":{"email":"tom@example.com"},"op":"update","update":{"$setOnInsert":{"age":20,"name":"Tom"}},"upsert":true}`
+	if err != nil || jsonStr != want {
+		t.Fatalf("do nothing JSON错误: %s %v", jsonStr, err)
+	}
+}
+
+func TestJsonParser_Aggregate(t *testing.T) {
+	p := &parser.JsonParser{DriverName: "json", DriverID: 1}
+	where := dbhelper.Cond().Eq("status", "paid").Build()
+	opts := &types.QueryOptions{
+		Aggregates: []types.Aggregate{types.Count("*", "cnt"), types.Sum("amount", "total")},
+		GroupBy:    []string{"user_id"},
+		Having:     dbhelper.Cond().Gt("total", 100).Build(),
+		Limit:      5,
+	}
+	jsonStr, _, err := p.ParseQueryAndCache(where, opts)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := `{"op":"aggregate","pipeline":[{"$match":{"status":"paid"}},{"$group":{"_id":{"user_id":"$user_id"},"cnt":{"$sum":1},"total":{"$sum":"$amount"}}},{"$match":{"total":{"$gt":100}}},{"$limit":5}]}`
+	if jsonStr != want {
+		t.Fatalf("聚合JSON错误: %s", jsonStr)
+	}
+}
+
+func TestJsonParser_SubQuery(t *testing.T) {
+	p := &parser.JsonParser{DriverName: "json", DriverID: 1}
+	where := dbhelper.Cond().
+		In("id", types.Sub("vip", nil, &types.QueryOptions{Columns: []string{"user_id"}})).
+		NotIn("role", []string{"guest"}).
+		Build()
+	jsonStr, _, err := p.ParseAndCache(types.OpQuery, where, nil)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := `{"filter":{"$and":[{"id":{"$in":{"$query":{"from":"vip","projection":{"user_id":1}}}}},{"role":{"$nin":["guest"]}}]},"op":"query"}`
+	if jsonStr != want {
+		t.Fatalf("子查询JSON错误: %s", jsonStr)
+	}
+}
+
+func TestJsonParser_NullAndNegation(t *testing.T) {
+	p := &parser.JsonParser{DriverName: "json", DriverID: 1}
+	where := dbhelper.Cond().
+		IsNull("deleted_at").
+		IsNotNull("email").
+		NotLike("name", "test%").
+		Between("age", 18, 30).
+		Not(dbhelper.Cond().Eq("status", "banned")).
+		Build()
+	jsonStr, _, err := p.ParseAndCache(types.OpQuery, where, nil)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := `{"filter":{"$and":[{"deleted_at":null},{"email":{"$exists":true,"$ne":null}},{"name":{"$not":{"$like":"test%"}}},{"age":{"$gte":18,"$lte":30}},{"$not":{"status":"banned"}}]},"op":"query"}`
+	if jsonStr != want {
+		t.Fatalf("空值与取反JSON错误: %s", jsonStr)
+	}
+}
+
+func TestJsonParser_UpdateOperators(t *testing.T) {
+	p := &parser.JsonParser{DriverName: "json", DriverID: 1}
+	set := dbhelper.Cond().
+		Eq("name", "Tom").
+		Inc("views", 1).
+		Dec("stock", 2).
+		Mul("price", 0.9).
+		SetNull("deleted_at").
+		SetIfNull("nickname", "anonymous").
+		Push("tags", "new").
+		Build()
+	jsonStr, _, err := p.Parse(types.OpUpdate, dbhelper.Cond().Eq("id", 1).Build(), set)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := `{"filter":{"id":1},"op":"update","update":{"$inc":{"stock":-2,"views":1},"$mul":{"price":0.9},"$push":{"tags":"new"},"$set":{"name":"Tom","nickname":{"$ifNull":["$nickname","anonymous"]}},"$unset":{"deleted_at":""}}}`
+	if jsonStr != want {
+		t.Fatalf("更新操作符JSON错误: %s", jsonStr)
+	}
+
+	if _, _, err = p.Parse(types.OpUpdate, nil, dbhelper.Cond().Like("name", "T%").Build()); err == nil {
+		t.Fatalf("不支持的更新操作应返回错误")
+	}
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/Kaguya154/dbhelper/dbtools"
@@ -12,123 +15,838 @@ type SQLParser struct {
 	DriverName string
 	DriverID   uint8
 	QuoteFunc  func(string) string
+	// PlaceholderFunc 返回第 n 个（从 1 开始）参数的占位符，为空时使用 '?'
+	PlaceholderFunc func(n int) string
+	// Dialect 决定分页等方言相关语法的生成方式
+	Dialect Dialect
+}
+
+// Dialect SQL 方言
+type Dialect uint8
+
+const (
+	DialectGeneric Dialect = iota
+	DialectSQLite
+	DialectMySQL
+	DialectPostgreSQL
+)
+
+// QuestionPlaceholder 问号占位符（SQLite、MySQL）
+func QuestionPlaceholder(n int) string {
+	return "?"
+}
+
+// DollarPlaceholder 编号占位符 $1, $2 ...（PostgreSQL）
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// NamedPlaceholder 返回带前缀的命名占位符策略，如 NamedPlaceholder(":p") 生成 :p1, :p2 ...
+func NamedPlaceholder(prefix string) func(n int) string {
+	return func(n int) string {
+		return prefix + strconv.Itoa(n)
+	}
 }
 
 var opStrMap = map[types.ConditionOp]string{
-	types.OpEq:   "=",
-	types.OpNe:   "<>",
-	types.OpGt:   ">",
-	types.OpGte:  ">=",
-	types.OpLt:   "<",
-	types.OpLte:  "<=",
-	types.OpLike: "LIKE",
+	types.OpEq:      "=",
+	types.OpNe:      "<>",
+	types.OpGt:      ">",
+	types.OpGte:     ">=",
+	types.OpLt:      "<",
+	types.OpLte:     "<=",
+	types.OpLike:    "LIKE",
+	types.OpNotLike: "NOT LIKE",
+}
+
+// sqlBuilder 保存一次解析过程中的 SQL 文本与参数，占位符按参数顺序编号。
+// argsOnly 为 true 时只收集参数、不生成 SQL 文本，用于缓存命中后重新绑定参数。
+// 表操作生成的是由驱动以 fmt.Sprintf 填充表名的模板，文本中的 % 写为 %%；
+// exec 为 true 时生成直接执行的 SQL，% 原样写入。
+type sqlBuilder struct {
+	p        *SQLParser
+	sb       strings.Builder
+	args     []interface{}
+	argsOnly bool
+	exec     bool
+	// aliases 构建 HAVING 时聚合别名到聚合表达式的映射
+	aliases map[string]types.Aggregate
+	// err 记录条件树内部（如子查询）产生的第一个错误
+	err error
+}
+
+func (b *sqlBuilder) writeString(s string) {
+	if b.argsOnly {
+		return
+	}
+	if !b.exec && strings.IndexByte(s, '%') >= 0 {
+		s = strings.ReplaceAll(s, "%", "%%")
+	}
+	b.sb.WriteString(s)
+}
+
+func (b *sqlBuilder) writeByte(c byte) {
+	if b.argsOnly {
+		return
+	}
+	if c == '%' && !b.exec {
+		b.sb.WriteByte('%')
+	}
+	b.sb.WriteByte(c)
+}
+
+// writeTable 写入由驱动填充表名的 %s 标记
+func (b *sqlBuilder) writeTable() {
+	if !b.argsOnly {
+		b.sb.WriteString("%s")
+	}
+}
+
+// writeQuoted 写入经过方言转义的标识符，alias.column 形式逐段转义，限定的 * 不转义
+func (b *sqlBuilder) writeQuoted(identifier string) {
+	if b.argsOnly {
+		return
+	}
+	for {
+		i := strings.IndexByte(identifier, '.')
+		if i < 0 {
+			break
+		}
+		b.writeString(b.p.QuoteFunc(identifier[:i]))
+		b.sb.WriteByte('.')
+		identifier = identifier[i+1:]
+	}
+	if identifier == "*" {
+		b.sb.WriteByte('*')
+		return
+	}
+	b.writeString(b.p.QuoteFunc(identifier))
+}
+
+// bind 追加一个参数并写入对应的占位符
+func (b *sqlBuilder) bind(v interface{}) {
+	b.args = append(b.args, v)
+	if b.argsOnly {
+		return
+	}
+	if b.p.PlaceholderFunc == nil {
+		b.sb.WriteByte('?')
+		return
+	}
+	b.writeString(b.p.PlaceholderFunc(len(b.args)))
 }
 
 func (p *SQLParser) Parse(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr) (string, []interface{}, error) {
-	var sb strings.Builder
-	var args []interface{}
-	switch op {
-	case types.OpInsert:
-		if where == nil || where.Op != types.OpAnd || len(where.Exprs) == 0 {
-			return "", nil, fmt.Errorf("Insert data must be AND expr with fields")
+	b := &sqlBuilder{p: p}
+	if err := b.build(op, where, set); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+func (p *SQLParser) ParseAndCache(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr) (string, []interface{}, error) {
+	key := dbtools.MakeCondCacheKey(p.DriverID, op, where, set)
+	if sqlStr, ok := dbtools.GetCondCache(key); ok {
+		// 模板命中，按当前条件重新收集参数
+		b := &sqlBuilder{p: p, argsOnly: true}
+		if err := b.build(op, where, set); err != nil {
+			return "", nil, err
 		}
-		sb.WriteString("INSERT INTO %s (")
-		for i, expr := range where.Exprs {
-			if expr.Op != types.OpEq {
-				return "", nil, fmt.Errorf("Insert only supports EQ expr")
-			}
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			sb.WriteString(p.QuoteFunc(expr.Field))
+		return sqlStr, b.args, nil
+	}
+	sqlStr, args, err := p.Parse(op, where, set)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, sqlStr)
+	return sqlStr, args, nil
+}
+
+// ParseQuery 生成带查询选项的 SELECT 语句，opts 为 nil 时等同于 Parse(types.OpQuery, where, nil)
+func (p *SQLParser) ParseQuery(where *types.ConditionExpr, opts *types.QueryOptions) (string, []interface{}, error) {
+	b := &sqlBuilder{p: p}
+	if err := b.buildSelect("", where, opts); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+func (p *SQLParser) ParseQueryAndCache(where *types.ConditionExpr, opts *types.QueryOptions) (string, []interface{}, error) {
+	key := dbtools.MakeQueryCacheKey(p.DriverID, where, opts)
+	if sqlStr, ok := dbtools.GetCondCache(key); ok {
+		b := &sqlBuilder{p: p, argsOnly: true}
+		if err := b.buildSelect("", where, opts); err != nil {
+			return "", nil, err
 		}
-		sb.WriteString(") VALUES (")
-		for i := range where.Exprs {
-			if i > 0 {
-				sb.WriteByte(',')
-			}
-			sb.WriteByte('?')
+		return sqlStr, b.args, nil
+	}
+	sqlStr, args, err := p.ParseQuery(where, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, sqlStr)
+	return sqlStr, args, nil
+}
+
+// ParseInsertMany 生成多行 INSERT 语句，rows 的列必须一致
+func (p *SQLParser) ParseInsertMany(rows []*types.ConditionExpr) (string, []interface{}, error) {
+	b := &sqlBuilder{p: p}
+	if err := b.buildInsert(rows); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+func (p *SQLParser) ParseInsertManyAndCache(rows []*types.ConditionExpr) (string, []interface{}, error) {
+	key := dbtools.MakeInsertManyCacheKey(p.DriverID, rows)
+	if sqlStr, ok := dbtools.GetCondCache(key); ok {
+		b := &sqlBuilder{p: p, argsOnly: true}
+		if err := b.buildInsert(rows); err != nil {
+			return "", nil, err
+		}
+		return sqlStr, b.args, nil
+	}
+	sqlStr, args, err := p.ParseInsertMany(rows)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, sqlStr)
+	return sqlStr, args, nil
+}
+
+// ParseUpsert 生成插入冲突时更新或忽略的 INSERT 语句：
+// SQLite/PostgreSQL 使用 ON CONFLICT，MySQL 使用 ON DUPLICATE KEY UPDATE
+func (p *SQLParser) ParseUpsert(data *types.ConditionExpr, opts *types.UpsertOptions) (string, []interface{}, error) {
+	b := &sqlBuilder{p: p}
+	if err := b.buildUpsert(data, opts); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+func (p *SQLParser) ParseUpsertAndCache(data *types.ConditionExpr, opts *types.UpsertOptions) (string, []interface{}, error) {
+	key := dbtools.MakeUpsertCacheKey(p.DriverID, data, opts)
+	if sqlStr, ok := dbtools.GetCondCache(key); ok {
+		b := &sqlBuilder{p: p, argsOnly: true}
+		if err := b.buildUpsert(data, opts); err != nil {
+			return "", nil, err
 		}
-		sb.WriteByte(')')
-		for _, expr := range where.Exprs {
-			args = append(args, expr.Value)
+		return sqlStr, b.args, nil
+	}
+	sqlStr, args, err := p.ParseUpsert(data, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, sqlStr)
+	return sqlStr, args, nil
+}
+
+// ParseReturning 生成带 RETURNING 子句的 Insert/Update/Delete 语句（SQLite 3.35+、PostgreSQL）
+func (p *SQLParser) ParseReturning(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr, returning []string) (string, []interface{}, error) {
+	b := &sqlBuilder{p: p}
+	if err := b.buildReturning(op, where, set, returning); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+func (p *SQLParser) ParseReturningAndCache(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr, returning []string) (string, []interface{}, error) {
+	key := dbtools.MakeReturningCacheKey(p.DriverID, op, where, set, returning)
+	if sqlStr, ok := dbtools.GetCondCache(key); ok {
+		b := &sqlBuilder{p: p, argsOnly: true}
+		if err := b.buildReturning(op, where, set, returning); err != nil {
+			return "", nil, err
 		}
+		return sqlStr, b.args, nil
+	}
+	sqlStr, args, err := p.ParseReturning(op, where, set, returning)
+	if err != nil {
+		return "", nil, err
+	}
+	dbtools.SetCondCache(key, sqlStr)
+	return sqlStr, args, nil
+}
+
+// build 按操作类型生成 SQL
+func (b *sqlBuilder) build(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr) error {
+	switch op {
+	case types.OpInsert:
+		return b.buildInsert([]*types.ConditionExpr{where})
 
 	case types.OpQuery:
-		sb.WriteString("SELECT * FROM %s")
-		if where != nil {
-			sb.WriteString(" WHERE ")
-			buildWhere(p.QuoteFunc, &sb, where, &args)
-		}
+		return b.buildSelect("", where, nil)
 
 	case types.OpUpdate:
 		if set == nil {
-			return "", nil, fmt.Errorf("Update data cannot be empty")
+			return fmt.Errorf("Update data cannot be empty")
 		}
-		sb.WriteString("UPDATE %s SET ")
-		first := true
+		b.writeString("UPDATE ")
+		b.writeTable()
+		b.writeString(" SET ")
 		if set.Op == types.OpAnd && len(set.Exprs) > 0 {
-			for _, expr := range set.Exprs {
-				if !first {
-					sb.WriteByte(',')
+			for i, expr := range set.Exprs {
+				if i > 0 {
+					b.writeByte(',')
+				}
+				if err := b.writeAssignment(expr); err != nil {
+					return err
 				}
-				sb.WriteString(p.QuoteFunc(expr.Field))
-				sb.WriteString("=?")
-				first = false
 			}
-		} else if set.Op == types.OpEq && set.Field != "" {
-			sb.WriteString(p.QuoteFunc(set.Field))
-			sb.WriteString("=?")
+		} else if set.Field != "" {
+			if err := b.writeAssignment(set); err != nil {
+				return err
+			}
 		} else {
-			return "", nil, fmt.Errorf("Invalid update data")
+			return fmt.Errorf("Invalid update data")
 		}
 		if where != nil {
-			sb.WriteString(" WHERE ")
-			var whereArgs []interface{}
-			var nilsb strings.Builder
-			buildWhere(p.QuoteFunc, &sb, where, &whereArgs)
-			buildWhere(p.QuoteFunc, &nilsb, set, &args)
-			args = append(args, whereArgs...)
+			b.writeString(" WHERE ")
+			b.buildWhere(where)
 		}
 
 	case types.OpDelete:
-		sb.WriteString("DELETE FROM %s")
+		b.writeString("DELETE FROM ")
+		b.writeTable()
 		if where != nil {
-			sb.WriteString(" WHERE ")
-			buildWhere(p.QuoteFunc, &sb, where, &args)
+			b.writeString(" WHERE ")
+			b.buildWhere(where)
 		}
 
 	case types.OpExec:
 		if where == nil || where.Op != types.OpRaw {
-			return "", nil, fmt.Errorf("Exec only supports OpRaw ConditionExpr")
+			return fmt.Errorf("Exec only supports OpRaw ConditionExpr")
 		}
 		execStr, ok := where.Value.(string)
 		if !ok {
-			return "", nil, fmt.Errorf("Exec OpRaw ConditionExpr.Value must be string")
+			return fmt.Errorf("Exec OpRaw ConditionExpr.Value must be string")
 		}
-		sb.WriteString(execStr)
+		b.exec = true
+		b.writeRaw(execStr, where.Values)
 
 	default:
-		return "", nil, fmt.Errorf("unsupported op: %d", op)
+		return fmt.Errorf("unsupported op: %d", op)
 	}
+	return b.err
+}
 
-	return sb.String(), args, nil
+var arithUpdateOps = map[types.ConditionOp]string{
+	types.OpInc: " + ",
+	types.OpDec: " - ",
+	types.OpMul: " * ",
 }
 
-func (p *SQLParser) ParseAndCache(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr) (string, []interface{}, error) {
-	if sqlStr, args, ok := dbtools.GetCondCache(p.DriverID, op, where); ok {
-		return sqlStr, args, nil
+// writeAssignment 写入 SET 中的一项赋值
+func (b *sqlBuilder) writeAssignment(expr *types.ConditionExpr) error {
+	if expr.Field == "" {
+		return fmt.Errorf("Update field cannot be empty")
 	}
-	sqlStr, args, err := p.Parse(op, where, set)
+	b.writeQuoted(expr.Field)
+	b.writeByte('=')
+	switch expr.Op {
+	case types.OpEq:
+		b.writeValue(expr.Value)
+	case types.OpInc, types.OpDec, types.OpMul:
+		if expr.Value == nil {
+			return fmt.Errorf("%s requires a value", expr.Op)
+		}
+		b.writeQuoted(expr.Field)
+		b.writeString(arithUpdateOps[expr.Op])
+		b.writeValue(expr.Value)
+	case types.OpSetNull:
+		b.writeString("NULL")
+	case types.OpSetIfNull:
+		b.writeString("COALESCE(")
+		b.writeQuoted(expr.Field)
+		b.writeByte(',')
+		b.writeValue(expr.Value)
+		b.writeByte(')')
+	case types.OpPush:
+		return b.writePush(expr)
+	default:
+		return fmt.Errorf("Update does not support %s expr", expr.Op)
+	}
+	return nil
+}
+
+// writePush 写入向 JSON 数组追加元素的表达式，字段为 NULL 时视为空数组
+func (b *sqlBuilder) writePush(expr *types.ConditionExpr) error {
+	data, err := json.Marshal(expr.Value)
 	if err != nil {
-		return "", nil, err
+		return fmt.Errorf("Push value cannot be encoded as JSON: %v", err)
 	}
-	dbtools.SetCondCache(p.DriverID, op, where, sqlStr, args)
-	return sqlStr, args, nil
+	switch b.p.Dialect {
+	case DialectMySQL:
+		b.writeString("JSON_ARRAY_APPEND(COALESCE(")
+		b.writeQuoted(expr.Field)
+		b.writeString(",JSON_ARRAY()),'$',CAST(")
+		b.bind(string(data))
+		b.writeString(" AS JSON))")
+	case DialectPostgreSQL:
+		b.writeString("COALESCE(")
+		b.writeQuoted(expr.Field)
+		b.writeString(",'[]'::jsonb) || jsonb_build_array(")
+		b.bind(string(data))
+		b.writeString("::jsonb)")
+	case DialectSQLite:
+		b.writeString("json_insert(COALESCE(")
+		b.writeQuoted(expr.Field)
+		b.writeString(",'[]'),'$[#]',json(")
+		b.bind(string(data))
+		b.writeString("))")
+	default:
+		return fmt.Errorf("Push is not supported by dialect %d", b.p.Dialect)
+	}
+	return nil
+}
+
+// buildInsert 构建单行或多行 INSERT 语句，所有行必须与第一行的列一致
+func (b *sqlBuilder) buildInsert(rows []*types.ConditionExpr) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("InsertMany rows cannot be empty")
+	}
+	first := rows[0]
+	if first == nil || first.Op != types.OpAnd || len(first.Exprs) == 0 {
+		return fmt.Errorf("Insert data must be AND expr with fields")
+	}
+	b.writeString("INSERT INTO ")
+	b.writeTable()
+	b.writeString(" (")
+	for i, expr := range first.Exprs {
+		if expr.Op != types.OpEq {
+			return fmt.Errorf("Insert only supports EQ expr")
+		}
+		if i > 0 {
+			b.writeByte(',')
+		}
+		b.writeQuoted(expr.Field)
+	}
+	b.writeString(") VALUES ")
+	for r, row := range rows {
+		if row == nil || row.Op != types.OpAnd || len(row.Exprs) != len(first.Exprs) {
+			return fmt.Errorf("InsertMany rows must have the same columns")
+		}
+		if r > 0 {
+			b.writeByte(',')
+		}
+		b.writeByte('(')
+		for i, expr := range row.Exprs {
+			if expr.Op != types.OpEq || expr.Field != first.Exprs[i].Field {
+				return fmt.Errorf("InsertMany rows must have the same columns")
+			}
+			if i > 0 {
+				b.writeByte(',')
+			}
+			b.bind(expr.Value)
+		}
+		b.writeByte(')')
+	}
+	return nil
+}
+
+// buildReturning 在 Insert/Update/Delete 语句后追加 RETURNING 子句
+func (b *sqlBuilder) buildReturning(op types.OpType, where *types.ConditionExpr, set *types.ConditionExpr, returning []string) error {
+	if b.p.Dialect == DialectMySQL {
+		return fmt.Errorf("RETURNING is not supported by MySQL")
+	}
+	if op != types.OpInsert && op != types.OpUpdate && op != types.OpDelete {
+		return fmt.Errorf("RETURNING only supports Insert, Update and Delete")
+	}
+	if len(returning) == 0 {
+		return fmt.Errorf("RETURNING columns cannot be empty")
+	}
+	if err := b.build(op, where, set); err != nil {
+		return err
+	}
+	b.writeString(" RETURNING ")
+	for i, col := range returning {
+		if i > 0 {
+			b.writeByte(',')
+		}
+		b.writeColumn(col)
+	}
+	return nil
+}
+
+// buildUpsert 构建带冲突处理的 INSERT 语句
+func (b *sqlBuilder) buildUpsert(data *types.ConditionExpr, opts *types.UpsertOptions) error {
+	if opts == nil || (!opts.DoNothing && len(opts.UpdateColumns) == 0) {
+		return fmt.Errorf("Upsert requires UpdateColumns or DoNothing")
+	}
+	if err := b.buildInsert([]*types.ConditionExpr{data}); err != nil {
+		return err
+	}
+	for _, col := range opts.UpdateColumns {
+		if !hasField(data, col) {
+			return fmt.Errorf("Upsert update column %s not in data", col)
+		}
+	}
+
+	if b.p.Dialect == DialectMySQL {
+		b.writeString(" ON DUPLICATE KEY UPDATE ")
+		if opts.DoNothing {
+			// 更新为自身，避免 INSERT IGNORE 同时吞掉其他错误
+			col := data.Exprs[0].Field
+			if len(opts.ConflictColumns) > 0 {
+				col = opts.ConflictColumns[0]
+			}
+			b.writeQuoted(col)
+			b.writeByte('=')
+			b.writeQuoted(col)
+			return nil
+		}
+		for i, col := range opts.UpdateColumns {
+			if i > 0 {
+				b.writeByte(',')
+			}
+			b.writeQuoted(col)
+			b.writeString("=VALUES(")
+			b.writeQuoted(col)
+			b.writeByte(')')
+		}
+		return nil
+	}
+
+	if len(opts.ConflictColumns) == 0 && !opts.DoNothing {
+		return fmt.Errorf("Upsert requires ConflictColumns")
+	}
+	b.writeString(" ON CONFLICT")
+	if len(opts.ConflictColumns) > 0 {
+		b.writeString(" (")
+		for i, col := range opts.ConflictColumns {
+			if i > 0 {
+				b.writeByte(',')
+			}
+			b.writeQuoted(col)
+		}
+		b.writeByte(')')
+	}
+	if opts.DoNothing {
+		b.writeString(" DO NOTHING")
+		return nil
+	}
+	b.writeString(" DO UPDATE SET ")
+	for i, col := range opts.UpdateColumns {
+		if i > 0 {
+			b.writeByte(',')
+		}
+		b.writeQuoted(col)
+		b.writeString("=excluded.")
+		b.writeQuoted(col)
+	}
+	return nil
+}
+
+func hasField(data *types.ConditionExpr, field string) bool {
+	for _, expr := range data.Exprs {
+		if expr.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSelect 构建 SELECT 语句，table 为空时写入 %s 由驱动填充表名
+func (b *sqlBuilder) buildSelect(table string, where *types.ConditionExpr, opts *types.QueryOptions) error {
+	if opts == nil {
+		opts = &types.QueryOptions{}
+	}
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return fmt.Errorf("Limit and Offset cannot be negative")
+	}
+	b.writeString("SELECT ")
+	if opts.Distinct {
+		b.writeString("DISTINCT ")
+	}
+	if len(opts.Columns) == 0 && len(opts.Aggregates) == 0 {
+		b.writeByte('*')
+	}
+	for i, col := range opts.Columns {
+		if col == "" {
+			return fmt.Errorf("Query column cannot be empty")
+		}
+		if i > 0 {
+			b.writeByte(',')
+		}
+		b.writeColumn(col)
+		if strings.IndexByte(col, '.') >= 0 && !strings.HasSuffix(col, ".*") {
+			// 限定列以完整名称作为结果列名，避免联表时同名列互相覆盖
+			b.writeString(" AS ")
+			b.writeString(b.p.QuoteFunc(col))
+		}
+	}
+	for i, a := range opts.Aggregates {
+		if i > 0 || len(opts.Columns) > 0 {
+			b.writeByte(',')
+		}
+		if err := b.writeAggregate(a); err != nil {
+			return err
+		}
+		if a.Alias != "" {
+			b.writeString(" AS ")
+			b.writeQuoted(a.Alias)
+		}
+	}
+	b.writeString(" FROM ")
+	if table == "" {
+		b.writeTable()
+	} else {
+		b.writeQuoted(table)
+	}
+	if opts.Alias != "" {
+		b.writeString(" AS ")
+		b.writeQuoted(opts.Alias)
+	}
+	for _, j := range opts.Joins {
+		if err := b.writeJoin(j); err != nil {
+			return err
+		}
+	}
+	if where != nil {
+		b.writeString(" WHERE ")
+		b.buildWhere(where)
+	}
+	for i, col := range opts.GroupBy {
+		if col == "" {
+			return fmt.Errorf("GroupBy column cannot be empty")
+		}
+		if i == 0 {
+			b.writeString(" GROUP BY ")
+		} else {
+			b.writeByte(',')
+		}
+		b.writeColumn(col)
+	}
+	if opts.Having != nil {
+		// HAVING 中的聚合别名替换为聚合表达式，PostgreSQL 不允许在 HAVING 中引用别名
+		b.aliases = make(map[string]types.Aggregate, len(opts.Aggregates))
+		for _, a := range opts.Aggregates {
+			if a.Alias != "" {
+				b.aliases[a.Alias] = a
+			}
+		}
+		b.writeString(" HAVING ")
+		b.buildWhere(opts.Having)
+		b.aliases = nil
+	}
+	for i, o := range opts.OrderBy {
+		if o.Field == "" {
+			return fmt.Errorf("OrderBy field cannot be empty")
+		}
+		if i == 0 {
+			b.writeString(" ORDER BY ")
+		} else {
+			b.writeByte(',')
+		}
+		b.writeColumn(o.Field)
+		if o.Desc {
+			b.writeString(" DESC")
+		} else {
+			b.writeString(" ASC")
+		}
+	}
+	if opts.Limit > 0 {
+		b.writeString(" LIMIT ")
+		b.bind(int64(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		if opts.Limit == 0 {
+			// SQLite 与 MySQL 不支持单独的 OFFSET
+			switch b.p.Dialect {
+			case DialectSQLite:
+				b.writeString(" LIMIT -1")
+			case DialectMySQL:
+				b.writeString(" LIMIT 18446744073709551615")
+			}
+		}
+		b.writeString(" OFFSET ")
+		b.bind(int64(opts.Offset))
+	}
+	if err := b.writeLock(opts); err != nil {
+		return err
+	}
+	return b.err
+}
+
+// writeLock 写入行锁子句，如 FOR UPDATE SKIP LOCKED
+func (b *sqlBuilder) writeLock(opts *types.QueryOptions) error {
+	if opts.Lock == "" {
+		if opts.LockWait != "" {
+			return fmt.Errorf("LockWait requires Lock")
+		}
+		return nil
+	}
+	if b.p.Dialect == DialectSQLite {
+		return fmt.Errorf("row locking (%s) is not supported by SQLite", opts.Lock)
+	}
+	if opts.Lock != types.LockForUpdate && opts.Lock != types.LockForShare {
+		return fmt.Errorf("unsupported lock mode: %s", opts.Lock)
+	}
+	b.writeByte(' ')
+	b.writeString(string(opts.Lock))
+	switch opts.LockWait {
+	case "":
+	case types.LockNoWait, types.LockSkipLocked:
+		b.writeByte(' ')
+		b.writeString(string(opts.LockWait))
+	default:
+		return fmt.Errorf("unsupported lock wait: %s", opts.LockWait)
+	}
+	return nil
+}
+
+var joinTypes = map[types.JoinType]bool{
+	types.JoinInner: true,
+	types.JoinLeft:  true,
+	types.JoinRight: true,
+	types.JoinCross: true,
+}
+
+// writeJoin 写入联表子句，ON 条件的参数按出现顺序排在 WHERE 之前
+func (b *sqlBuilder) writeJoin(j types.Join) error {
+	if !joinTypes[j.Type] {
+		return fmt.Errorf("unsupported join type: %s", j.Type)
+	}
+	if j.Table == "" {
+		return fmt.Errorf("Join table cannot be empty")
+	}
+	if j.Type == types.JoinCross {
+		if j.On != nil {
+			return fmt.Errorf("CROSS JOIN cannot have ON condition")
+		}
+	} else if j.On == nil {
+		return fmt.Errorf("%s requires ON condition", j.Type)
+	}
+	b.writeByte(' ')
+	b.writeString(string(j.Type))
+	b.writeByte(' ')
+	b.writeQuoted(j.Table)
+	if j.Alias != "" {
+		b.writeString(" AS ")
+		b.writeQuoted(j.Alias)
+	}
+	if j.On != nil {
+		b.writeString(" ON ")
+		b.buildWhere(j.On)
+	}
+	return nil
+}
+
+var aggregateFuncs = map[types.AggregateFunc]bool{
+	types.AggCount: true,
+	types.AggSum:   true,
+	types.AggAvg:   true,
+	types.AggMin:   true,
+	types.AggMax:   true,
+}
+
+// writeAggregate 写入聚合表达式，如 COUNT(*)、SUM(DISTINCT `amount`)
+func (b *sqlBuilder) writeAggregate(a types.Aggregate) error {
+	if !aggregateFuncs[a.Func] {
+		return fmt.Errorf("unsupported aggregate: %s", a.Func)
+	}
+	b.writeString(string(a.Func))
+	b.writeByte('(')
+	if a.Distinct {
+		b.writeString("DISTINCT ")
+	}
+	if a.Field == "" || a.Field == "*" {
+		if a.Func != types.AggCount || a.Distinct {
+			return fmt.Errorf("%s requires a field", a.Func)
+		}
+		b.writeByte('*')
+	} else {
+		b.writeColumn(a.Field)
+	}
+	b.writeByte(')')
+	return nil
+}
+
+// writeField 写入条件中的字段，构建 HAVING 时聚合别名替换为聚合表达式
+func (b *sqlBuilder) writeField(field string) {
+	if a, ok := b.aliases[field]; ok {
+		b.writeAggregate(a)
+		return
+	}
+	b.writeQuoted(field)
+}
+
+// writeValue 写入条件或更新数据的值，列引用与表达式直接渲染，子查询内联，其余绑定为参数
+func (b *sqlBuilder) writeValue(v interface{}) {
+	switch v := v.(type) {
+	case types.Column:
+		b.writeQuoted(string(v))
+	case *types.SubQuery:
+		b.writeSubQuery(v)
+	case *types.ArithExpr:
+		if !arithOps[v.Op] {
+			b.fail(fmt.Errorf("unsupported arithmetic operator: %s", v.Op))
+			return
+		}
+		b.writeByte('(')
+		b.writeValue(v.Left)
+		b.writeByte(' ')
+		b.writeString(v.Op)
+		b.writeByte(' ')
+		b.writeValue(v.Right)
+		b.writeByte(')')
+	case *types.FuncExpr:
+		b.writeFunc(v)
+	default:
+		b.bind(v)
+	}
+}
+
+var arithOps = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true}
+
+// writeFunc 写入函数调用，SQLite 没有 NOW()，改写为 CURRENT_TIMESTAMP
+func (b *sqlBuilder) writeFunc(f *types.FuncExpr) {
+	if f.Name == "" || strings.IndexFunc(f.Name, func(r rune) bool { return r > 0x7f || !isIdentByte(byte(r)) }) >= 0 {
+		b.fail(fmt.Errorf("invalid function name: %q", f.Name))
+		return
+	}
+	if b.p.Dialect == DialectSQLite && len(f.Args) == 0 && strings.EqualFold(f.Name, "NOW") {
+		b.writeString("CURRENT_TIMESTAMP")
+		return
+	}
+	b.writeString(f.Name)
+	b.writeByte('(')
+	for i, arg := range f.Args {
+		if i > 0 {
+			b.writeByte(',')
+		}
+		b.writeValue(arg)
+	}
+	b.writeByte(')')
+}
+
+// writeSubQuery 内联写入带括号的子查询，参数按出现顺序并入外层
+func (b *sqlBuilder) writeSubQuery(q *types.SubQuery) {
+	if q == nil || q.Table == "" {
+		b.fail(fmt.Errorf("SubQuery table cannot be empty"))
+		return
+	}
+	// 子查询有自己的 HAVING 别名作用域
+	aliases := b.aliases
+	b.aliases = nil
+	b.writeByte('(')
+	if err := b.buildSelect(q.Table, q.Where, q.Options); err != nil {
+		b.fail(err)
+	}
+	b.writeByte(')')
+	b.aliases = aliases
+}
+
+// writeColumn 写入列名，* 不做转义
+func (b *sqlBuilder) writeColumn(col string) {
+	if col == "*" {
+		b.writeByte('*')
+		return
+	}
+	b.writeQuoted(col)
 }
 
 // buildWhere 递归构建 WHERE 子句
-func buildWhere(quote func(string) string, sb *strings.Builder, cond *types.ConditionExpr, args *[]interface{}) {
+func (b *sqlBuilder) buildWhere(cond *types.ConditionExpr) {
 	if cond == nil {
 		return
 	}
@@ -144,42 +862,224 @@ func buildWhere(quote func(string) string, sb *strings.Builder, cond *types.Cond
 				continue
 			}
 			if !first {
-				sb.WriteString(sep)
+				b.writeString(sep)
 			}
-			sb.WriteByte('(')
-			buildWhere(quote, sb, expr, args)
-			sb.WriteByte(')')
+			b.writeByte('(')
+			b.buildWhere(expr)
+			b.writeByte(')')
 			first = false
 		}
-	case types.OpEq, types.OpNe, types.OpGt, types.OpGte, types.OpLt, types.OpLte, types.OpLike:
+	case types.OpNot:
+		if len(cond.Exprs) == 0 {
+			b.fail(fmt.Errorf("NOT requires at least one condition"))
+			return
+		}
+		b.writeString("NOT (")
+		if len(cond.Exprs) == 1 {
+			b.buildWhere(cond.Exprs[0])
+		} else {
+			b.buildWhere(&types.ConditionExpr{Op: types.OpAnd, Exprs: cond.Exprs})
+		}
+		b.writeByte(')')
+	case types.OpIsNull:
+		b.writeField(cond.Field)
+		b.writeString(" IS NULL")
+	case types.OpNotNull:
+		b.writeField(cond.Field)
+		b.writeString(" IS NOT NULL")
+	case types.OpBetween:
+		if len(cond.Values) != 2 {
+			b.fail(fmt.Errorf("BETWEEN requires 2 values, got %d", len(cond.Values)))
+			return
+		}
+		b.writeField(cond.Field)
+		b.writeString(" BETWEEN ")
+		b.writeValue(cond.Values[0])
+		b.writeString(" AND ")
+		b.writeValue(cond.Values[1])
+	case types.OpEq, types.OpNe, types.OpGt, types.OpGte, types.OpLt, types.OpLte, types.OpLike, types.OpNotLike:
+		if (cond.Op == types.OpEq || cond.Op == types.OpNe) && types.IsNilValue(cond.Value) {
+			// = NULL 永远不成立，改写为 IS NULL / IS NOT NULL
+			b.writeField(cond.Field)
+			if cond.Op == types.OpEq {
+				b.writeString(" IS NULL")
+			} else {
+				b.writeString(" IS NOT NULL")
+			}
+			return
+		}
 		if opStr, ok := opStrMap[cond.Op]; ok {
-			sb.WriteString(quote(cond.Field))
-			sb.WriteByte(' ')
-			sb.WriteString(opStr)
-			sb.WriteString(" ?")
-			*args = append(*args, cond.Value)
+			b.writeField(cond.Field)
+			b.writeByte(' ')
+			b.writeString(opStr)
+			b.writeByte(' ')
+			b.writeValue(cond.Value)
+		}
+	case types.OpIn, types.OpNotIn:
+		opStr := " IN "
+		if cond.Op == types.OpNotIn {
+			opStr = " NOT IN "
+		}
+		if q, ok := cond.Value.(*types.SubQuery); ok {
+			b.writeField(cond.Field)
+			b.writeString(opStr)
+			b.writeSubQuery(q)
+			return
 		}
-	case types.OpIn:
 		if len(cond.Values) == 0 {
-			sb.WriteString("1=0")
+			// 空列表：IN 恒假，NOT IN 恒真
+			if cond.Op == types.OpIn {
+				b.writeString("1=0")
+			} else {
+				b.writeString("1=1")
+			}
 			return
 		}
-		sb.WriteString(quote(cond.Field))
-		sb.WriteString(" IN (")
-		for i := range cond.Values {
+		b.writeField(cond.Field)
+		b.writeString(opStr)
+		b.writeByte('(')
+		for i, v := range cond.Values {
 			if i > 0 {
-				sb.WriteByte(',')
+				b.writeByte(',')
 			}
-			sb.WriteByte('?')
+			b.writeValue(v)
+		}
+		b.writeByte(')')
+	case types.OpExists, types.OpNotExists:
+		if cond.Op == types.OpNotExists {
+			b.writeString("NOT ")
 		}
-		sb.WriteByte(')')
-		*args = append(*args, cond.Values...)
+		b.writeString("EXISTS ")
+		q, _ := cond.Value.(*types.SubQuery)
+		b.writeSubQuery(q)
 	case types.OpRaw:
 		if s, ok := cond.Value.(string); ok {
-			sb.WriteString(s)
+			b.writeRaw(s, cond.Values)
 		}
-		if cond.Values != nil {
-			*args = append(*args, cond.Values...)
+	}
+}
+
+// fail 记录第一个错误
+func (b *sqlBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// writeRaw 写入原始 SQL 片段。带参数时将引号外的 ? 或 :name 改写为方言占位符，
+// 参数按占位符出现的顺序绑定；命名参数使用 sql.Named 传入，同名参数可以出现多次。
+// PostgreSQL 还接受 $n 形式的占位符，按编号绑定 args[n-1]，不能与 ? 混用。
+// 不带参数的片段原样写入，以兼容 PostgreSQL 的 ? 运算符。
+func (b *sqlBuilder) writeRaw(raw string, args []interface{}) {
+	if len(args) == 0 {
+		b.writeString(raw)
+		return
+	}
+	var named map[string]interface{}
+	if _, ok := args[0].(sql.NamedArg); ok {
+		named = make(map[string]interface{}, len(args))
+		for _, a := range args {
+			na, ok := a.(sql.NamedArg)
+			if !ok || na.Name == "" {
+				b.fail(fmt.Errorf("Raw cannot mix named and positional args"))
+				return
+			}
+			named[na.Name] = na.Value
 		}
 	}
+
+	var quote byte
+	var used []bool
+	next, start := 0, 0
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && named == nil:
+			if used != nil {
+				b.fail(fmt.Errorf("Raw cannot mix ? and $n placeholders"))
+				return
+			}
+			if next >= len(args) {
+				b.fail(fmt.Errorf("Raw has more placeholders than args (%d)", len(args)))
+				return
+			}
+			b.writeString(raw[start:i])
+			b.writeValue(args[next])
+			next++
+			start = i + 1
+		case c == '$' && named == nil && b.p.Dialect == DialectPostgreSQL &&
+			i+1 < len(raw) && isDigit(raw[i+1]) && (i == 0 || !isIdentByte(raw[i-1])):
+			if next > 0 {
+				b.fail(fmt.Errorf("Raw cannot mix ? and $n placeholders"))
+				return
+			}
+			j := i + 1
+			n := 0
+			for j < len(raw) && isDigit(raw[j]) {
+				n = n*10 + int(raw[j]-'0')
+				j++
+			}
+			if n < 1 || n > len(args) {
+				b.fail(fmt.Errorf("Raw placeholder $%d out of range (%d args)", n, len(args)))
+				return
+			}
+			if used == nil {
+				used = make([]bool, len(args))
+			}
+			used[n-1] = true
+			b.writeString(raw[start:i])
+			b.writeValue(args[n-1])
+			start = j
+			i = j - 1
+		case c == ':' && named != nil:
+			if i+1 < len(raw) && raw[i+1] == ':' {
+				// PostgreSQL 类型转换 ::type
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(raw) && isIdentByte(raw[j]) {
+				j++
+			}
+			if j == i+1 {
+				continue
+			}
+			v, ok := named[raw[i+1:j]]
+			if !ok {
+				b.fail(fmt.Errorf("Raw named arg %s not provided", raw[i+1:j]))
+				return
+			}
+			b.writeString(raw[start:i])
+			b.writeValue(v)
+			start = j
+			i = j - 1
+		}
+	}
+	for n, ok := range used {
+		if !ok {
+			b.fail(fmt.Errorf("Raw arg $%d is not referenced", n+1))
+			return
+		}
+	}
+	if named == nil && used == nil && next != len(args) {
+		b.fail(fmt.Errorf("Raw has %d placeholders but %d args", next, len(args)))
+		return
+	}
+	b.writeString(raw[start:])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
 }
@@ -1,12 +1,25 @@
 package parser_test
 
 import (
+	"database/sql"
+	"reflect"
 	"testing"
 
+	"github.com/Kaguya154/dbhelper"
+	"github.com/Kaguya154/dbhelper/drivers/mysql"
+	"github.com/Kaguya154/dbhelper/drivers/postgresql"
+	"github.com/Kaguya154/dbhelper/drivers/sqlite"
 	"github.com/Kaguya154/dbhelper/parser"
 	"github.com/Kaguya154/dbhelper/types"
 )
 
+func init() {
+	// 注册驱动
+	_ = dbhelper.RegisterDriver(sqlite.DriverName, sqlite.GetDriver())
+	_ = dbhelper.RegisterDriver(mysql.DriverName, mysql.GetDriver())
+	_ = dbhelper.RegisterDriver(postgresql.DriverName, postgresql.GetDriver())
+}
+
 func quoteSql(field string) string {
 	return "`" + field + "`"
 }
@@ -68,3 +81,616 @@ func BenchmarkSqlParseComplexCond(b *testing.B) {
 		}
 	})
 }
+
+func TestSQLParser_Placeholder(t *testing.T) {
+	where := dbhelper.Cond().Eq("name", "Tom").In("role", []interface{}{"admin", "user"}).Build()
+	data := dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).Build()
+	upd := dbhelper.Cond().Eq("age", 21).Eq("status", "active").Build()
+
+	cases := []struct {
+		driver string
+		insert string
+		query  string
+		update string
+		delete string
+	}{
+		{
+			driver: sqlite.DriverName,
+			insert: "INSERT INTO %s (`name`,`age`) VALUES (?,?)",
+			query:  "SELECT * FROM %s WHERE (`name` = ?) AND (`role` IN (?,?))",
+			update: "UPDATE %s SET `age`=?,`status`=? WHERE (`name` = ?) AND (`role` IN (?,?))",
+			delete: "DELETE FROM %s WHERE (`name` = ?) AND (`role` IN (?,?))",
+		},
+		{
+			driver: mysql.DriverName,
+			insert: "INSERT INTO %s (`name`,`age`) VALUES (?,?)",
+			query:  "SELECT * FROM %s WHERE (`name` = ?) AND (`role` IN (?,?))",
+			update: "UPDATE %s SET `age`=?,`status`=? WHERE (`name` = ?) AND (`role` IN (?,?))",
+			delete: "DELETE FROM %s WHERE (`name` = ?) AND (`role` IN (?,?))",
+		},
+		{
+			driver: postgresql.DriverName,
+			insert: `INSERT INTO %s ("name","age") VALUES ($1,$2)`,
+			query:  `SELECT * FROM %s WHERE ("name" = $1) AND ("role" IN ($2,$3))`,
+			update: `UPDATE %s SET "age"=$1,"status"=$2 WHERE ("name" = $3) AND ("role" IN ($4,$5))`,
+			delete: `DELETE FROM %s WHERE ("name" = $1) AND ("role" IN ($2,$3))`,
+		},
+	}
+
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		p := driver.Parser()
+		// 解析器的占位符取自驱动
+		if sp, ok := p.(*parser.SQLParser); !ok || sp.PlaceholderFunc(2) != driver.Placeholder(2) {
+			t.Errorf("%s 解析器占位符与驱动不一致", c.driver)
+		}
+
+		sqlStr, args, err := p.Parse(types.OpInsert, data, nil)
+		if err != nil || sqlStr != c.insert || !reflect.DeepEqual(args, []interface{}{"Tom", 20}) {
+			t.Errorf("%s 插入SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+		sqlStr, args, err = p.Parse(types.OpQuery, where, nil)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{"Tom", "admin", "user"}) {
+			t.Errorf("%s 查询SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+		sqlStr, args, err = p.Parse(types.OpUpdate, where, upd)
+		if err != nil || sqlStr != c.update || !reflect.DeepEqual(args, []interface{}{21, "active", "Tom", "admin", "user"}) {
+			t.Errorf("%s 更新SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+		sqlStr, args, err = p.Parse(types.OpDelete, where, nil)
+		if err != nil || sqlStr != c.delete || !reflect.DeepEqual(args, []interface{}{"Tom", "admin", "user"}) {
+			t.Errorf("%s 删除SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+}
+
+func TestSQLParser_NamedPlaceholder(t *testing.T) {
+	p := &parser.SQLParser{
+		DriverName:      "named",
+		DriverID:        200,
+		QuoteFunc:       quoteSql,
+		PlaceholderFunc: parser.NamedPlaceholder("@p"),
+	}
+	where := dbhelper.Cond().Eq("id", 1).Build()
+	upd := dbhelper.Cond().Eq("age", 21).Build()
+	sqlStr, args, err := p.Parse(types.OpUpdate, where, upd)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if sqlStr != "UPDATE %s SET `age`=@p1 WHERE `id` = @p2" || !reflect.DeepEqual(args, []interface{}{21, 1}) {
+		t.Fatalf("命名占位符SQL错误: %s %v", sqlStr, args)
+	}
+
+	// 无 WHERE 时 SET 参数也必须保留
+	sqlStr, args, err = p.Parse(types.OpUpdate, nil, upd)
+	if err != nil || sqlStr != "UPDATE %s SET `age`=@p1" || !reflect.DeepEqual(args, []interface{}{21}) {
+		t.Fatalf("无条件更新SQL错误: %s %v %v", sqlStr, args, err)
+	}
+}
+
+func TestSQLParser_QueryOptions(t *testing.T) {
+	where := dbhelper.Cond().Eq("status", "active").Build()
+	opts := &types.QueryOptions{
+		Columns:  []string{"id", "name"},
+		Distinct: true,
+		OrderBy:  []types.OrderBy{types.Desc("age"), types.Asc("name")},
+		Limit:    10,
+		Offset:   20,
+	}
+	offsetOnly := &types.QueryOptions{Offset: 5}
+
+	cases := []struct {
+		driver     string
+		query      string
+		offsetOnly string
+	}{
+		{
+			driver:     sqlite.DriverName,
+			query:      "SELECT DISTINCT `id`,`name` FROM %s WHERE `status` = ? ORDER BY `age` DESC,`name` ASC LIMIT ? OFFSET ?",
+			offsetOnly: "SELECT * FROM %s LIMIT -1 OFFSET ?",
+		},
+		{
+			driver:     mysql.DriverName,
+			query:      "SELECT DISTINCT `id`,`name` FROM %s WHERE `status` = ? ORDER BY `age` DESC,`name` ASC LIMIT ? OFFSET ?",
+			offsetOnly: "SELECT * FROM %s LIMIT 18446744073709551615 OFFSET ?",
+		},
+		{
+			driver:     postgresql.DriverName,
+			query:      `SELECT DISTINCT "id","name" FROM %s WHERE "status" = $1 ORDER BY "age" DESC,"name" ASC LIMIT $2 OFFSET $3`,
+			offsetOnly: `SELECT * FROM %s OFFSET $1`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseQueryAndCache(where, opts)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{"active", int64(10), int64(20)}) {
+			t.Errorf("%s 查询选项SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+		sqlStr, args, err = driver.Parser().ParseQueryAndCache(nil, offsetOnly)
+		if err != nil || sqlStr != c.offsetOnly || !reflect.DeepEqual(args, []interface{}{int64(5)}) {
+			t.Errorf("%s 仅OFFSET SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	p := &parser.SQLParser{DriverName: "mysql", DriverID: 1, QuoteFunc: quoteSql}
+	if _, _, err := p.ParseQuery(nil, &types.QueryOptions{Limit: -1}); err == nil {
+		t.Fatalf("负数 Limit 应返回错误")
+	}
+}
+
+func TestSQLParser_InsertMany(t *testing.T) {
+	rows := []*types.ConditionExpr{
+		dbhelper.Cond().Eq("name", "Tom").Eq("age", 20).Build(),
+		dbhelper.Cond().Eq("name", "Jerry").Eq("age", 21).Build(),
+	}
+	driver, _ := dbhelper.GetDriver(postgresql.DriverName)
+	sqlStr, args, err := driver.Parser().ParseInsertManyAndCache(rows)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if sqlStr != `INSERT INTO %s ("name","age") VALUES ($1,$2),($3,$4)` || !reflect.DeepEqual(args, []interface{}{"Tom", 20, "Jerry", 21}) {
+		t.Fatalf("多行插入SQL错误: %s %v", sqlStr, args)
+	}
+
+	bad := append(rows, dbhelper.Cond().Eq("name", "Alice").Eq("email", "a@example.com").Build())
+	if _, _, err = driver.Parser().ParseInsertManyAndCache(bad); err == nil {
+		t.Fatalf("列不一致应返回错误")
+	}
+}
+
+func TestSQLParser_Upsert(t *testing.T) {
+	data := dbhelper.Cond().Eq("email", "tom@example.com").Eq("name", "Tom").Eq("age", 20).Build()
+	update := &types.UpsertOptions{ConflictColumns: []string{"email"}, UpdateColumns: []string{"name", "age"}}
+	nothing := &types.UpsertOptions{ConflictColumns: []string{"email"}, DoNothing: true}
+
+	cases := []struct {
+		driver  string
+		update  string
+		nothing string
+	}{
+		{
+			driver:  sqlite.DriverName,
+			update:  "INSERT INTO %s (`email`,`name`,`age`) VALUES (?,?,?) ON CONFLICT (`email`) DO UPDATE SET `name`=excluded.`name`,`age`=excluded.`age`",
+			nothing: "INSERT INTO %s (`email`,`name`,`age`) VALUES (?,?,?) ON CONFLICT (`email`) DO NOTHING",
+		},
+		{
+			driver:  mysql.DriverName,
+			update:  "INSERT INTO %s (`email`,`name`,`age`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`age`=VALUES(`age`)",
+			nothing: "INSERT INTO %s (`email`,`name`,`age`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `email`=`email`",
+		},
+		{
+			driver:  postgresql.DriverName,
+			update:  `INSERT INTO %s ("email","name","age") VALUES ($1,$2,$3) ON CONFLICT ("email") DO UPDATE SET "name"=excluded."name","age"=excluded."age"`,
+			nothing: `INSERT INTO %s ("email","name","age") VALUES ($1,$2,$3) ON CONFLICT ("email") DO NOTHING`,
+		},
+	}
+	for _, c := range cases {
+		driver, _ := dbhelper.GetDriver(c.driver)
+		sqlStr, args, err := driver.Parser().ParseUpsertAndCache(data, update)
+		if err != nil || sqlStr != c.update || len(args) != 3 {
+			t.Errorf("%s upsert SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+		sqlStr, _, err = driver.Parser().ParseUpsertAndCache(data, nothing)
+		if err != nil || sqlStr != c.nothing {
+			t.Errorf("%s do nothing SQL错误: %s %v", c.driver, sqlStr, err)
+		}
+		if _, _, err = driver.Parser().ParseUpsert(data, &types.UpsertOptions{ConflictColumns: []string{"email"}, UpdateColumns: []string{"nick"}}); err == nil {
+			t.Errorf("%s 更新列不在数据中应返回错误", c.driver)
+		}
+	}
+}
+
+func TestSQLParser_Returning(t *testing.T) {
+	where := dbhelper.Cond().Eq("name", "Tom").Build()
+	upd := dbhelper.Cond().Eq("age", 21).Build()
+	driver, _ := dbhelper.GetDriver(postgresql.DriverName)
+	sqlStr, args, err := driver.Parser().ParseReturningAndCache(types.OpUpdate, where, upd, []string{"id", "age"})
+	if err != nil || sqlStr != `UPDATE %s SET "age"=$1 WHERE "name" = $2 RETURNING "id","age"` || !reflect.DeepEqual(args, []interface{}{21, "Tom"}) {
+		t.Fatalf("RETURNING SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	driver, _ = dbhelper.GetDriver(mysql.DriverName)
+	if _, _, err = driver.Parser().ParseReturning(types.OpDelete, where, nil, []string{"id"}); err == nil {
+		t.Fatalf("MySQL 不支持 RETURNING，应返回错误")
+	}
+}
+
+func TestSQLParser_Aggregate(t *testing.T) {
+	where := dbhelper.Cond().Eq("status", "paid").Build()
+	opts := &types.QueryOptions{
+		Columns:    []string{"user_id"},
+		Aggregates: []types.Aggregate{types.Count("*", "cnt"), types.Sum("amount", "total")},
+		GroupBy:    []string{"user_id"},
+		Having:     dbhelper.Cond().Gt("total", 100).Build(),
+		OrderBy:    []types.OrderBy{types.Desc("total")},
+	}
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: sqlite.DriverName,
+			query:  "SELECT `user_id`,COUNT(*) AS `cnt`,SUM(`amount`) AS `total` FROM %s WHERE `status` = ? GROUP BY `user_id` HAVING SUM(`amount`) > ? ORDER BY `total` DESC",
+		},
+		{
+			driver: mysql.DriverName,
+			query:  "SELECT `user_id`,COUNT(*) AS `cnt`,SUM(`amount`) AS `total` FROM %s WHERE `status` = ? GROUP BY `user_id` HAVING SUM(`amount`) > ? ORDER BY `total` DESC",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `SELECT "user_id",COUNT(*) AS "cnt",SUM("amount") AS "total" FROM %s WHERE "status" = $1 GROUP BY "user_id" HAVING SUM("amount") > $2 ORDER BY "total" DESC`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseQueryAndCache(where, opts)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{"paid", 100}) {
+			t.Errorf("%s 聚合SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	driver, _ := dbhelper.GetDriver(sqlite.DriverName)
+	if _, _, err := driver.Parser().ParseQuery(nil, &types.QueryOptions{Aggregates: []types.Aggregate{types.Sum("*", "s")}}); err == nil {
+		t.Errorf("SUM(*) 应返回错误")
+	}
+}
+
+func TestSQLParser_Join(t *testing.T) {
+	where := dbhelper.Cond().Eq("u.status", "active").Build()
+	opts := &types.QueryOptions{
+		Alias: "u",
+		Joins: []types.Join{
+			types.LeftJoin("orders", "o", dbhelper.Cond().Eq("o.user_id", types.Col("u.id")).Gt("o.amount", 10).Build()),
+		},
+		Columns: []string{"u.id", "o.*", "name"},
+		OrderBy: []types.OrderBy{types.Desc("o.amount")},
+	}
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: sqlite.DriverName,
+			query:  "SELECT `u`.`id` AS `u.id`,`o`.*,`name` FROM %s AS `u` LEFT JOIN `orders` AS `o` ON (`o`.`user_id` = `u`.`id`) AND (`o`.`amount` > ?) WHERE `u`.`status` = ? ORDER BY `o`.`amount` DESC",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `SELECT "u"."id" AS "u.id","o".*,"name" FROM %s AS "u" LEFT JOIN "orders" AS "o" ON ("o"."user_id" = "u"."id") AND ("o"."amount" > $1) WHERE "u"."status" = $2 ORDER BY "o"."amount" DESC`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseQueryAndCache(where, opts)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{10, "active"}) {
+			t.Errorf("%s 联表SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	driver, _ := dbhelper.GetDriver(sqlite.DriverName)
+	if _, _, err := driver.Parser().ParseQuery(nil, &types.QueryOptions{Joins: []types.Join{types.InnerJoin("orders", "o", nil)}}); err == nil {
+		t.Errorf("缺少 ON 条件应返回错误")
+	}
+	sqlStr, _, err := driver.Parser().ParseQuery(nil, &types.QueryOptions{Joins: []types.Join{types.CrossJoin("tags", "")}})
+	if err != nil || sqlStr != "SELECT * FROM %s CROSS JOIN `tags`" {
+		t.Errorf("CROSS JOIN SQL错误: %s %v", sqlStr, err)
+	}
+}
+
+func TestSQLParser_SubQuery(t *testing.T) {
+	banned := types.Sub("banned", dbhelper.Cond().Gt("level", 2).Build(), &types.QueryOptions{Columns: []string{"user_id"}})
+	where := dbhelper.Cond().
+		Eq("status", "active").
+		NotIn("id", banned).
+		Exists(types.Sub("orders", dbhelper.Cond().Eq("orders.user_id", types.Col("user.id")).Gte("amount", 100).Build())).
+		Gt("age", types.Sub("user", nil, &types.QueryOptions{Aggregates: []types.Aggregate{types.Avg("age", "")}})).
+		Build()
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: sqlite.DriverName,
+			query:  "SELECT * FROM %s WHERE (`status` = ?) AND (`id` NOT IN (SELECT `user_id` FROM `banned` WHERE `level` > ?)) AND (EXISTS (SELECT * FROM `orders` WHERE (`orders`.`user_id` = `user`.`id`) AND (`amount` >= ?))) AND (`age` > (SELECT AVG(`age`) FROM `user`))",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `SELECT * FROM %s WHERE ("status" = $1) AND ("id" NOT IN (SELECT "user_id" FROM "banned" WHERE "level" > $2)) AND (EXISTS (SELECT * FROM "orders" WHERE ("orders"."user_id" = "user"."id") AND ("amount" >= $3))) AND ("age" > (SELECT AVG("age") FROM "user"))`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseQueryAndCache(where, nil)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{"active", 2, 100}) {
+			t.Errorf("%s 子查询SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	// Update 的 SET 参数排在子查询参数之前
+	driver, _ := dbhelper.GetDriver(postgresql.DriverName)
+	sqlStr, args, err := driver.Parser().ParseAndCache(types.OpUpdate, dbhelper.Cond().In("id", banned).Build(), dbhelper.Cond().Eq("status", "banned").Build())
+	want := `UPDATE %s SET "status"=$1 WHERE "id" IN (SELECT "user_id" FROM "banned" WHERE "level" > $2)`
+	if err != nil || sqlStr != want || !reflect.DeepEqual(args, []interface{}{"banned", 2}) {
+		t.Errorf("子查询更新SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	if _, _, err = driver.Parser().Parse(types.OpQuery, dbhelper.Cond().In("id", types.Sub("", nil)).Build(), nil); err == nil {
+		t.Errorf("子查询缺少表名应返回错误")
+	}
+}
+
+func TestSQLParser_NullAndNegation(t *testing.T) {
+	where := dbhelper.Cond().
+		Eq("deleted_at", nil).
+		Ne("email", nil).
+		NotLike("name", "test%").
+		Between("age", 18, 30).
+		NotIn("role", []interface{}{"guest"}).
+		Not(dbhelper.Cond().Eq("status", "banned"), dbhelper.Cond().IsNull("verified_at")).
+		Build()
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: sqlite.DriverName,
+			query:  "SELECT * FROM %s WHERE (`deleted_at` IS NULL) AND (`email` IS NOT NULL) AND (`name` NOT LIKE ?) AND (`age` BETWEEN ? AND ?) AND (`role` NOT IN (?)) AND (NOT ((`status` = ?) AND (`verified_at` IS NULL)))",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `SELECT * FROM %s WHERE ("deleted_at" IS NULL) AND ("email" IS NOT NULL) AND ("name" NOT LIKE $1) AND ("age" BETWEEN $2 AND $3) AND ("role" NOT IN ($4)) AND (NOT (("status" = $5) AND ("verified_at" IS NULL)))`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseAndCache(types.OpQuery, where, nil)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{"test%", 18, 30, "guest", "banned"}) {
+			t.Errorf("%s 空值与取反SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	// 值为 nil 与非 nil 的相同结构不能共享缓存模板
+	driver, _ := dbhelper.GetDriver(sqlite.DriverName)
+	sqlStr, args, err := driver.Parser().ParseAndCache(types.OpQuery, dbhelper.Cond().Eq("deleted_at", "2024-01-01").Build(), nil)
+	if err != nil || sqlStr != "SELECT * FROM %s WHERE `deleted_at` = ?" || len(args) != 1 {
+		t.Errorf("非空值SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	// 带类型的空指针（如结构体字段）同样改写为 IS NULL，且不命中非空值的缓存
+	sqlStr, args, err = driver.Parser().ParseAndCache(types.OpQuery, dbhelper.Cond().Eq("deleted_at", (*string)(nil)).Build(), nil)
+	if err != nil || sqlStr != "SELECT * FROM %s WHERE `deleted_at` IS NULL" || len(args) != 0 {
+		t.Errorf("空指针SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	// 没有内层条件的 NOT 无法生成合法 SQL
+	if _, _, err = driver.Parser().Parse(types.OpQuery, dbhelper.Cond().Not().Build(), nil); err == nil {
+		t.Errorf("空 NOT 应返回错误")
+	}
+
+	// 更新时 nil 仍然绑定为 NULL
+	sqlStr, args, err = driver.Parser().Parse(types.OpUpdate, dbhelper.Cond().Eq("id", 1).Build(), dbhelper.Cond().Eq("deleted_at", nil).Build())
+	if err != nil || sqlStr != "UPDATE %s SET `deleted_at`=? WHERE `id` = ?" || !reflect.DeepEqual(args, []interface{}{nil, 1}) {
+		t.Errorf("更新为NULL的SQL错误: %s %v %v", sqlStr, args, err)
+	}
+}
+
+func TestSQLParser_RawArgs(t *testing.T) {
+	driver, err := dbhelper.GetDriver(postgresql.DriverName)
+	if err != nil {
+		t.Fatalf("获取驱动失败: %v", err)
+	}
+	p := driver.Parser()
+
+	// 原始片段中的 ? 与其他参数一起按顺序编号，引号内的 ? 不改写
+	where := dbhelper.Cond().Eq("status", "active").Raw("LOWER(email) = ? AND note <> '?'", "tom@example.com").Build()
+	sqlStr, args, err := p.ParseAndCache(types.OpUpdate, where, dbhelper.Cond().Eq("age", 20).Build())
+	want := `UPDATE %s SET "age"=$1 WHERE ("status" = $2) AND (LOWER(email) = $3 AND note <> '?')`
+	if err != nil || sqlStr != want || !reflect.DeepEqual(args, []interface{}{20, "active", "tom@example.com"}) {
+		t.Errorf("原始条件参数SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	// 命名参数可重复出现，:: 类型转换保持不变
+	where = dbhelper.Cond().Raw("created_at::date = :day OR updated_at::date = :day AND owner = :owner",
+		sql.Named("day", "2024-01-01"), sql.Named("owner", 7)).Build()
+	sqlStr, args, err = p.ParseAndCache(types.OpQuery, where, nil)
+	want = `SELECT * FROM %s WHERE created_at::date = $1 OR updated_at::date = $2 AND owner = $3`
+	if err != nil || sqlStr != want || !reflect.DeepEqual(args, []interface{}{"2024-01-01", "2024-01-01", 7}) {
+		t.Errorf("命名参数SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	// Exec 同样支持参数绑定
+	sqlStr, args, err = p.ParseAndCache(types.OpExec, dbhelper.Cond().Raw("DELETE FROM logs WHERE level = ? AND ts < ?", "debug", 100).Build(), nil)
+	if err != nil || sqlStr != "DELETE FROM logs WHERE level = $1 AND ts < $2" || !reflect.DeepEqual(args, []interface{}{"debug", 100}) {
+		t.Errorf("Exec参数SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	// PostgreSQL 的 $n 按编号绑定，与其他参数一起重新编号，$$ 与标识符中的 $ 不改写
+	where = dbhelper.Cond().Eq("status", "active").Raw("owner = $2 AND (note = $1 OR memo = $1) AND tag$1 <> $$x$$", "a", 7).Build()
+	sqlStr, args, err = p.ParseAndCache(types.OpQuery, where, nil)
+	want = `SELECT * FROM %s WHERE ("status" = $1) AND (owner = $2 AND (note = $3 OR memo = $4) AND tag$1 <> $$x$$)`
+	if err != nil || sqlStr != want || !reflect.DeepEqual(args, []interface{}{"active", 7, "a", "a"}) {
+		t.Errorf("$n 参数SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	for _, cond := range []*types.ConditionExpr{
+		dbhelper.Cond().Raw("a = ? AND b = ?", 1).Build(),
+		dbhelper.Cond().Raw("a = ?", 1, 2).Build(),
+		dbhelper.Cond().Raw("a = :a", sql.Named("b", 1)).Build(),
+		dbhelper.Cond().Raw("a = :a", sql.Named("a", 1), 2).Build(),
+		dbhelper.Cond().Raw("a = $1 AND b = ?", 1, 2).Build(),
+		dbhelper.Cond().Raw("a = $2", 1).Build(),
+		dbhelper.Cond().Raw("a = $1", 1, 2).Build(),
+	} {
+		if _, _, err = p.Parse(types.OpQuery, cond, nil); err == nil {
+			t.Errorf("参数不匹配应返回错误: %v", cond.Value)
+		}
+	}
+}
+
+func TestSQLParser_Expr(t *testing.T) {
+	where := dbhelper.Cond().
+		Gt("updated_at", types.Col("created_at")).
+		Lt("balance", types.Col("credit").Mul(2).Sub(10)).
+		Build()
+	set := dbhelper.Cond().
+		Eq("counter", types.Col("counter").Add(1)).
+		Eq("nickname", types.Fn("COALESCE", types.Col("nickname"), "anonymous")).
+		Eq("updated_at", types.Fn("NOW")).
+		Build()
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: sqlite.DriverName,
+			query:  "UPDATE %s SET `counter`=(`counter` + ?),`nickname`=COALESCE(`nickname`,?),`updated_at`=CURRENT_TIMESTAMP WHERE (`updated_at` > `created_at`) AND (`balance` < ((`credit` * ?) - ?))",
+		},
+		{
+			driver: mysql.DriverName,
+			query:  "UPDATE %s SET `counter`=(`counter` + ?),`nickname`=COALESCE(`nickname`,?),`updated_at`=NOW() WHERE (`updated_at` > `created_at`) AND (`balance` < ((`credit` * ?) - ?))",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `UPDATE %s SET "counter"=("counter" + $1),"nickname"=COALESCE("nickname",$2),"updated_at"=NOW() WHERE ("updated_at" > "created_at") AND ("balance" < (("credit" * $3) - $4))`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseAndCache(types.OpUpdate, where, set)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{1, "anonymous", 2, 10}) {
+			t.Errorf("%s 表达式SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	driver, _ := dbhelper.GetDriver(sqlite.DriverName)
+	// IN 列表中的列引用与表达式同样按 SQL 渲染
+	in := dbhelper.Cond().In("a", []interface{}{types.Col("b"), types.Col("c").Add(1), 2}).Build()
+	sqlStr, args, err := driver.Parser().ParseAndCache(types.OpQuery, in, nil)
+	if err != nil || sqlStr != "SELECT * FROM %s WHERE `a` IN (`b`,(`c` + ?),?)" || !reflect.DeepEqual(args, []interface{}{1, 2}) {
+		t.Errorf("IN 列表表达式SQL错误: %s %v %v", sqlStr, args, err)
+	}
+
+	for _, v := range []interface{}{types.Fn("NOW(); DROP TABLE user; --"), types.Arith(types.Col("a"), "||", 1)} {
+		if _, _, err := driver.Parser().Parse(types.OpQuery, dbhelper.Cond().Eq("a", v).Build(), nil); err == nil {
+			t.Errorf("非法表达式应返回错误: %v", v)
+		}
+	}
+}
+
+func TestSQLParser_UpdateOperators(t *testing.T) {
+	where := dbhelper.Cond().Eq("id", 1).Build()
+	set := dbhelper.Cond().
+		Inc("views", 1).
+		Dec("stock", 2).
+		Mul("price", 0.9).
+		SetNull("deleted_at").
+		SetIfNull("nickname", "anonymous").
+		Push("tags", "new").
+		Build()
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: sqlite.DriverName,
+			query:  "UPDATE %s SET `views`=`views` + ?,`stock`=`stock` - ?,`price`=`price` * ?,`deleted_at`=NULL,`nickname`=COALESCE(`nickname`,?),`tags`=json_insert(COALESCE(`tags`,'[]'),'$[#]',json(?)) WHERE `id` = ?",
+		},
+		{
+			driver: mysql.DriverName,
+			query:  "UPDATE %s SET `views`=`views` + ?,`stock`=`stock` - ?,`price`=`price` * ?,`deleted_at`=NULL,`nickname`=COALESCE(`nickname`,?),`tags`=JSON_ARRAY_APPEND(COALESCE(`tags`,JSON_ARRAY()),'$',CAST(? AS JSON)) WHERE `id` = ?",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `UPDATE %s SET "views"="views" + $1,"stock"="stock" - $2,"price"="price" * $3,"deleted_at"=NULL,"nickname"=COALESCE("nickname",$4),"tags"=COALESCE("tags",'[]'::jsonb) || jsonb_build_array($5::jsonb) WHERE "id" = $6`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, args, err := driver.Parser().ParseAndCache(types.OpUpdate, where, set)
+		if err != nil || sqlStr != c.query || !reflect.DeepEqual(args, []interface{}{1, 2, 0.9, "anonymous", `"new"`, 1}) {
+			t.Errorf("%s 更新操作符SQL错误: %s %v %v", c.driver, sqlStr, args, err)
+		}
+	}
+
+	driver, _ := dbhelper.GetDriver(sqlite.DriverName)
+	if _, _, err := driver.Parser().Parse(types.OpUpdate, where, dbhelper.Cond().Gt("views", 1).Build()); err == nil {
+		t.Errorf("不支持的更新操作应返回错误")
+	}
+}
+
+func TestSQLParser_Lock(t *testing.T) {
+	where := dbhelper.Cond().Eq("status", "pending").Build()
+	opts := &types.QueryOptions{
+		OrderBy:  []types.OrderBy{types.Asc("id")},
+		Limit:    10,
+		Lock:     types.LockForUpdate,
+		LockWait: types.LockSkipLocked,
+	}
+
+	cases := []struct {
+		driver string
+		query  string
+	}{
+		{
+			driver: mysql.DriverName,
+			query:  "SELECT * FROM %s WHERE `status` = ? ORDER BY `id` ASC LIMIT ? FOR UPDATE SKIP LOCKED",
+		},
+		{
+			driver: postgresql.DriverName,
+			query:  `SELECT * FROM %s WHERE "status" = $1 ORDER BY "id" ASC LIMIT $2 FOR UPDATE SKIP LOCKED`,
+		},
+	}
+	for _, c := range cases {
+		driver, err := dbhelper.GetDriver(c.driver)
+		if err != nil {
+			t.Fatalf("获取驱动失败: %v", err)
+		}
+		sqlStr, _, err := driver.Parser().ParseQueryAndCache(where, opts)
+		if err != nil || sqlStr != c.query {
+			t.Errorf("%s 行锁SQL错误: %s %v", c.driver, sqlStr, err)
+		}
+	}
+
+	driver, _ := dbhelper.GetDriver(postgresql.DriverName)
+	sqlStr, _, err := driver.Parser().ParseQueryAndCache(where, &types.QueryOptions{Lock: types.LockForShare, LockWait: types.LockNoWait})
+	if err != nil || sqlStr != `SELECT * FROM %s WHERE "status" = $1 FOR SHARE NOWAIT` {
+		t.Errorf("共享锁SQL错误: %s %v", sqlStr, err)
+	}
+	if _, _, err = driver.Parser().ParseQuery(where, &types.QueryOptions{LockWait: types.LockNoWait}); err == nil {
+		t.Errorf("LockWait 缺少 Lock 应返回错误")
+	}
+
+	driver, _ = dbhelper.GetDriver(sqlite.DriverName)
+	if _, _, err = driver.Parser().ParseQueryAndCache(where, opts); err == nil {
+		t.Errorf("SQLite 行锁应返回错误")
+	}
+}
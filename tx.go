@@ -0,0 +1,118 @@
+package dbhelper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/Kaguya154/dbhelper/types"
+)
+
+// TxOptions WithTx 的事务选项，零值表示默认隔离级别、读写事务、最多重试 3 次
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxRetries 冲突时的最大重试次数，0 使用默认值 3，负数不重试
+	MaxRetries int
+	// Backoff 首次重试前的等待时间，之后每次翻倍并加入随机抖动，0 使用默认值 10ms
+	Backoff time.Duration
+	// MaxBackoff 单次等待时间上限，0 使用默认值 1s
+	MaxBackoff time.Duration
+	// RetryIf 判断错误是否需要重试，为空时使用 IsRetryable
+	RetryIf func(err error) bool
+}
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 10 * time.Millisecond
+	defaultMaxBackoff = time.Second
+)
+
+// WithTx 在事务中执行 fn：fn 返回 nil 时提交，返回错误或 panic 时回滚（panic 会在回滚后继续抛出）。
+// 死锁、序列化失败等冲突会按 opts 重试整个事务，因此 fn 可能被执行多次，不应包含事务外的副作用
+func WithTx(conn types.Conn, opts *TxOptions, fn func(tx types.Tx) error) error {
+	return WithTxContext(context.Background(), conn, opts, fn)
+}
+
+func WithTxContext(ctx context.Context, conn types.Conn, opts *TxOptions, fn func(tx types.Tx) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	retryIf := opts.RetryIf
+	if retryIf == nil {
+		retryIf = IsRetryable
+	}
+	txOpts := &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, conn, txOpts, fn)
+		if err == nil || attempt >= maxRetries || !retryIf(err) {
+			return err
+		}
+		// 指数退避，抖动范围 [d/2, d)
+		d := backoff << attempt
+		if d > maxBackoff || d <= 0 {
+			d = maxBackoff
+		}
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// runTx 执行一次事务
+func runTx(ctx context.Context, conn types.Conn, opts *sql.TxOptions, fn func(tx types.Tx) error) (err error) {
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsRetryable 判断错误是否为可重试的事务冲突：已分类为 ErrDeadlock、ErrSerialization 的错误，
+// 或由已注册的驱动识别的原始错误
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, types.ErrDeadlock) || errors.Is(err, types.ErrSerialization) {
+		return true
+	}
+	registeredDriversMu.RLock()
+	defer registeredDriversMu.RUnlock()
+	for _, drv := range registeredDrivers {
+		if rc, ok := drv.(types.RetryClassifier); ok && rc.IsRetryable(err) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,41 @@
 package types
 
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
 const (
-	OpEq   ConditionOp = "EQ"
-	OpNe   ConditionOp = "NE"
-	OpGt   ConditionOp = "GT"
-	OpGte  ConditionOp = "GTE"
-	OpLt   ConditionOp = "LT"
-	OpLte  ConditionOp = "LTE"
-	OpLike ConditionOp = "LIKE"
-	OpIn   ConditionOp = "IN"
-	OpAnd  ConditionOp = "AND"
-	OpOr   ConditionOp = "OR"
-	OpRaw  ConditionOp = "RAW"
+	OpEq        ConditionOp = "EQ"
+	OpNe        ConditionOp = "NE"
+	OpGt        ConditionOp = "GT"
+	OpGte       ConditionOp = "GTE"
+	OpLt        ConditionOp = "LT"
+	OpLte       ConditionOp = "LTE"
+	OpLike      ConditionOp = "LIKE"
+	OpNotLike   ConditionOp = "NOT_LIKE"
+	OpIsNull    ConditionOp = "IS_NULL"
+	OpNotNull   ConditionOp = "IS_NOT_NULL"
+	OpBetween   ConditionOp = "BETWEEN"
+	OpIn        ConditionOp = "IN"
+	OpNotIn     ConditionOp = "NOT_IN"
+	OpExists    ConditionOp = "EXISTS"
+	OpNotExists ConditionOp = "NOT_EXISTS"
+	OpNot       ConditionOp = "NOT"
+	OpAnd       ConditionOp = "AND"
+	OpOr        ConditionOp = "OR"
+	OpRaw       ConditionOp = "RAW"
+)
+
+// 更新操作符，仅用于 Update 的 set 数据
+const (
+	OpInc       ConditionOp = "INC"
+	OpDec       ConditionOp = "DEC"
+	OpMul       ConditionOp = "MUL"
+	OpSetNull   ConditionOp = "SET_NULL"
+	OpSetIfNull ConditionOp = "SET_IF_NULL"
+	OpPush      ConditionOp = "PUSH"
 )
 
 // NewCondition 创建并返回一个新的 CondBuilder 实例。
@@ -91,16 +115,96 @@ func (b *CondBuilder) Like(field string, pattern string) *CondBuilder {
 	return b
 }
 
-// In 添加 IN 查询条件。
-func (b *CondBuilder) In(field string, values []interface{}) *CondBuilder {
+// NotLike 添加模糊不匹配条件（NOT LIKE）。
+func (b *CondBuilder) NotLike(field string, pattern string) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpNotLike,
+		Field: field,
+		Value: pattern,
+	})
+	return b
+}
+
+// IsNull 添加为空条件（IS NULL）。Eq(field, nil) 等价于 IsNull(field)。
+func (b *CondBuilder) IsNull(field string) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpIsNull,
+		Field: field,
+	})
+	return b
+}
+
+// IsNotNull 添加非空条件（IS NOT NULL）。Ne(field, nil) 等价于 IsNotNull(field)。
+func (b *CondBuilder) IsNotNull(field string) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpNotNull,
+		Field: field,
+	})
+	return b
+}
+
+// Between 添加闭区间条件（BETWEEN low AND high）。
+func (b *CondBuilder) Between(field string, low, high interface{}) *CondBuilder {
 	b.exprs = append(b.exprs, &ConditionExpr{
-		Op:     OpIn,
+		Op:     OpBetween,
 		Field:  field,
-		Values: values,
+		Values: []interface{}{low, high},
 	})
 	return b
 }
 
+// In 添加 IN 查询条件，values 可以是切片或 *SubQuery。
+func (b *CondBuilder) In(field string, values interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, inExpr(OpIn, field, values))
+	return b
+}
+
+// NotIn 添加 NOT IN 查询条件，values 可以是切片或 *SubQuery。
+func (b *CondBuilder) NotIn(field string, values interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, inExpr(OpNotIn, field, values))
+	return b
+}
+
+// Exists 添加 EXISTS 子查询条件。
+func (b *CondBuilder) Exists(q *SubQuery) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpExists,
+		Value: q,
+	})
+	return b
+}
+
+// NotExists 添加 NOT EXISTS 子查询条件。
+func (b *CondBuilder) NotExists(q *SubQuery) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpNotExists,
+		Value: q,
+	})
+	return b
+}
+
+// inExpr 子查询保存在 Value，切片展开为 Values，单个值视为只有一个元素的列表
+func inExpr(op ConditionOp, field string, values interface{}) *ConditionExpr {
+	expr := &ConditionExpr{Op: op, Field: field}
+	switch v := values.(type) {
+	case *SubQuery:
+		expr.Value = v
+	case []interface{}:
+		expr.Values = v
+	default:
+		rv := reflect.ValueOf(values)
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			expr.Values = make([]interface{}, rv.Len())
+			for i := range expr.Values {
+				expr.Values[i] = rv.Index(i).Interface()
+			}
+		} else {
+			expr.Values = []interface{}{values}
+		}
+	}
+	return expr
+}
+
 // And 组合多个条件为 AND。
 func (b *CondBuilder) And(conds ...*CondBuilder) *CondBuilder {
 	exprs := make([]*ConditionExpr, 0)
@@ -127,12 +231,86 @@ func (b *CondBuilder) Or(conds ...*CondBuilder) *CondBuilder {
 	return b
 }
 
-// Raw 添加原始条件（不安全，慎用）。
-func (b *CondBuilder) Raw(raw string) *CondBuilder {
+// Not 对多个条件的 AND 取反。
+func (b *CondBuilder) Not(conds ...*CondBuilder) *CondBuilder {
+	exprs := make([]*ConditionExpr, 0)
+	for _, c := range conds {
+		exprs = append(exprs, c.exprs...)
+	}
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpNot,
+		Exprs: exprs,
+	})
+	return b
+}
+
+// Raw 添加原始条件，片段中的 ? 按顺序绑定 args，也可以用 sql.Named 传入 :name 形式的命名参数；
+// PostgreSQL 还可以使用 $n 按编号绑定 args。
+// 片段本身原样写入 SQL，不要拼接外部输入。
+func (b *CondBuilder) Raw(raw string, args ...interface{}) *CondBuilder {
 	b.exprs = append(b.exprs, &ConditionExpr{
 		Op:     OpRaw,
 		Value:  raw,
-		Values: nil,
+		Values: args,
+	})
+	return b
+}
+
+// Inc 更新时将字段加上 n（field = field + n）。
+func (b *CondBuilder) Inc(field string, n interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpInc,
+		Field: field,
+		Value: n,
+	})
+	return b
+}
+
+// Dec 更新时将字段减去 n（field = field - n）。
+func (b *CondBuilder) Dec(field string, n interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpDec,
+		Field: field,
+		Value: n,
+	})
+	return b
+}
+
+// Mul 更新时将字段乘以 n（field = field * n）。
+func (b *CondBuilder) Mul(field string, n interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpMul,
+		Field: field,
+		Value: n,
+	})
+	return b
+}
+
+// SetNull 更新时将字段置为 NULL。
+func (b *CondBuilder) SetNull(field string) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpSetNull,
+		Field: field,
+	})
+	return b
+}
+
+// SetIfNull 更新时仅在字段为 NULL 时写入 value（field = COALESCE(field, value)）。
+func (b *CondBuilder) SetIfNull(field string, value interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpSetIfNull,
+		Field: field,
+		Value: value,
+	})
+	return b
+}
+
+// Push 更新时向 JSON 数组字段末尾追加 value，字段为 NULL 时视为空数组；value 以 JSON 编码绑定。
+func (b *CondBuilder) Push(field string, value interface{}) *CondBuilder {
+	b.exprs = append(b.exprs, &ConditionExpr{
+		Op:    OpPush,
+		Field: field,
+		Value: value,
 	})
 	return b
 }
@@ -152,3 +330,93 @@ func (b *CondBuilder) Build() *ConditionExpr {
 		Exprs: b.exprs,
 	}
 }
+
+// FromStruct 将带 db 标签的结构体（或其指针）转换为 Insert/Update 使用的数据表达式。
+// 列按字段声明顺序排列；忽略 `db:"-"`、readonly 字段，omitempty 与 autoincr 字段为零值时跳过。
+// 返回值总是 AND 表达式，即使只有一列。
+func FromStruct(v interface{}) (*ConditionExpr, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("FromStruct requires a non-nil struct")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("FromStruct requires a struct, got %s", rv.Type())
+	}
+	info := getStructInfo(rv.Type())
+	exprs := make([]*ConditionExpr, 0, len(info.fields))
+	for _, fi := range info.fields {
+		if fi.readOnly {
+			continue
+		}
+		f, ok := fieldByIndexRead(rv, fi.index)
+		if !ok {
+			continue
+		}
+		if (fi.omitEmpty || fi.autoIncr) && f.IsZero() {
+			continue
+		}
+		exprs = append(exprs, &ConditionExpr{
+			Op:    OpEq,
+			Field: fi.name,
+			Value: f.Interface(),
+		})
+	}
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("FromStruct found no writable fields in %s", rv.Type())
+	}
+	return &ConditionExpr{Op: OpAnd, Exprs: exprs}, nil
+}
+
+// FromMap 将列名到值的 map 转换为 Insert/Update 使用的数据表达式，列按名称排序以保证生成的 SQL 稳定。
+// 返回值总是 AND 表达式，即使只有一列。
+func FromMap(m map[string]interface{}) (*ConditionExpr, error) {
+	if len(m) == 0 {
+		return nil, fmt.Errorf("FromMap requires a non-empty map")
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k == "" {
+			return nil, fmt.Errorf("FromMap column name cannot be empty")
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	exprs := make([]*ConditionExpr, 0, len(keys))
+	for _, k := range keys {
+		exprs = append(exprs, &ConditionExpr{
+			Op:    OpEq,
+			Field: k,
+			Value: m[k],
+		})
+	}
+	return &ConditionExpr{Op: OpAnd, Exprs: exprs}, nil
+}
+
+// FromMaps 将多个 map 转换为 InsertMany 使用的数据表达式，所有 map 必须包含相同的列
+func FromMaps(ms []map[string]interface{}) ([]*ConditionExpr, error) {
+	rows := make([]*ConditionExpr, 0, len(ms))
+	for _, m := range ms {
+		row, err := FromMap(m)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// IsNilValue 判断值是否为 nil，包括 (*string)(nil) 这类带类型的空指针与空接口
+func IsNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package types
+
+import (
+	"database/sql"
+	"iter"
+)
+
+// Cursor 流式结果游标，逐行读取 *sql.Rows，不会把全部结果加载到内存
+type Cursor struct {
+	rows    *sql.Rows
+	columns []string
+	row     []interface{}
+	ptrs    []interface{}
+	loaded  bool
+	err     error
+}
+
+// NewCursor 包装 *sql.Rows，调用方负责 Close
+func NewCursor(rows *sql.Rows) (*Cursor, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	c := &Cursor{
+		rows:    rows,
+		columns: columns,
+		row:     make([]interface{}, len(columns)),
+		ptrs:    make([]interface{}, len(columns)),
+	}
+	for i := range c.row {
+		c.ptrs[i] = &c.row[i]
+	}
+	return c, nil
+}
+
+// Next 移动到下一行，返回是否有数据；没有更多数据时自动关闭游标
+func (c *Cursor) Next() bool {
+	c.loaded = false
+	if c.err != nil {
+		return false
+	}
+	return c.rows.Next()
+}
+
+// Scan 将当前行扫描到 dest，用法同 sql.Rows.Scan
+func (c *Cursor) Scan(dest ...interface{}) error {
+	return c.rows.Scan(dest...)
+}
+
+// Columns 返回结果列名
+func (c *Cursor) Columns() []string {
+	return c.columns
+}
+
+// Err 返回遍历过程中遇到的错误
+func (c *Cursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.rows.Err()
+}
+
+// Close 关闭游标，可重复调用
+func (c *Cursor) Close() error {
+	return c.rows.Close()
+}
+
+// load 惰性扫描当前行，供 Get 系列方法使用
+func (c *Cursor) load() bool {
+	if c.loaded {
+		return true
+	}
+	if err := c.rows.Scan(c.ptrs...); err != nil {
+		c.err = err
+		return false
+	}
+	c.loaded = true
+	return true
+}
+
+// Get 原始取值
+func (c *Cursor) Get(col string) interface{} {
+	if !c.load() {
+		return nil
+	}
+	for i, name := range c.columns {
+		if name == col {
+			return c.row[i]
+		}
+	}
+	return nil
+}
+
+// GetString 取字符串
+func (c *Cursor) GetString(col string) string {
+	return toString(c.Get(col))
+}
+
+// GetInt 取整数
+func (c *Cursor) GetInt(col string) int {
+	return toInt(c.Get(col))
+}
+
+// Map 以 map 形式返回当前行
+func (c *Cursor) Map() map[string]interface{} {
+	if !c.load() {
+		return nil
+	}
+	m := make(map[string]interface{}, len(c.columns))
+	for i, col := range c.columns {
+		m[col] = c.row[i]
+	}
+	return m
+}
+
+// Collect 读取剩余的所有行为 Rows 并关闭游标
+func (c *Cursor) Collect() (*Rows, error) {
+	defer c.Close()
+	result := []map[string]interface{}{}
+	for c.Next() {
+		m := c.Map()
+		if m == nil {
+			break
+		}
+		result = append(result, m)
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	return NewRows(result), nil
+}
+
+// Iter 返回可用于 range-over-func 的迭代器，遍历结束或提前退出时关闭游标；
+// 出错时最后一次迭代返回 (nil, err)
+func (c *Cursor) Iter() iter.Seq2[*Cursor, error] {
+	return func(yield func(*Cursor, error) bool) {
+		defer c.Close()
+		for c.Next() {
+			if !yield(c, nil) {
+				return
+			}
+		}
+		if err := c.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// 驱动错误的统一分类，使用 errors.Is 判断，如 errors.Is(err, types.ErrUniqueViolation)
+var (
+	// ErrNotFound 没有匹配的行，与 sql.ErrNoRows 相同，ScanOne 等在没有数据时返回
+	ErrNotFound = sql.ErrNoRows
+	// ErrUniqueViolation 违反唯一约束或主键约束
+	ErrUniqueViolation = errors.New("unique constraint violation")
+	// ErrForeignKeyViolation 违反外键约束
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	// ErrNotNullViolation 向非空列写入 NULL
+	ErrNotNullViolation = errors.New("not null constraint violation")
+	// ErrDeadlock 死锁或等待锁超时，可以重试整个事务
+	ErrDeadlock = errors.New("deadlock or lock timeout")
+	// ErrSerialization 序列化失败，可以重试整个事务
+	ErrSerialization = errors.New("serialization failure")
+	// ErrConnection 无法连接数据库或连接已断开
+	ErrConnection = errors.New("connection error")
+)
+
+// DBError 已分类的驱动错误，errors.Is 匹配 Kind，errors.As 仍可取得原始驱动错误
+type DBError struct {
+	// Kind 错误分类，为上面的哨兵错误之一
+	Kind error
+	// Table 操作的表，原始 SQL 与驱动无法提供时为空
+	Table string
+	// Constraint 违反的约束名，非空约束为列名，驱动无法提供时为空；SQLite 为 "表.列" 形式
+	Constraint string
+	// SQL 出错的语句
+	SQL string
+	// Err 原始驱动错误
+	Err error
+}
+
+func (e *DBError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Kind.Error())
+	if e.Table != "" {
+		sb.WriteString(" on table ")
+		sb.WriteString(e.Table)
+	}
+	if e.Constraint != "" {
+		sb.WriteString(" (constraint ")
+		sb.WriteString(e.Constraint)
+		sb.WriteString(")")
+	}
+	if e.Err != nil {
+		sb.WriteString(": ")
+		sb.WriteString(e.Err.Error())
+	}
+	return sb.String()
+}
+
+func (e *DBError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// ErrorClassifier 由驱动实现，将原始驱动错误映射为 DBError，无法分类时返回 nil
+type ErrorClassifier interface {
+	ClassifyError(err error) *DBError
+}
@@ -1,22 +1,116 @@
 package types
 
+import (
+	"context"
+	"database/sql"
+)
+
 type Conn interface {
 	Insert(table string, data *ConditionExpr) (int64, error)
-	Query(table string, cond *ConditionExpr) (*Rows, error)
-	Update(table string, data, cond *ConditionExpr) (int64, error)
+	Query(table string, cond *ConditionExpr, opts ...*QueryOptions) (*Rows, error)
+	Update(table string, where, set *ConditionExpr) (int64, error)
 	Delete(table string, cond *ConditionExpr) (int64, error)
 	Exec(cond *ConditionExpr) (int64, error)
 	Begin() (Tx, error)
+	Close() error
+	Ping() error
+	PingContext(ctx context.Context) error
+	Stats() sql.DBStats
+
+	InsertContext(ctx context.Context, table string, data *ConditionExpr) (int64, error)
+	QueryContext(ctx context.Context, table string, cond *ConditionExpr, opts ...*QueryOptions) (*Rows, error)
+	UpdateContext(ctx context.Context, table string, where, set *ConditionExpr) (int64, error)
+	DeleteContext(ctx context.Context, table string, cond *ConditionExpr) (int64, error)
+	ExecContext(ctx context.Context, cond *ConditionExpr) (int64, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+
+	QueryIter(table string, cond *ConditionExpr, opts ...*QueryOptions) (*Cursor, error)
+	QueryIterContext(ctx context.Context, table string, cond *ConditionExpr, opts ...*QueryOptions) (*Cursor, error)
+	QueryRaw(query string, args ...interface{}) (*Rows, error)
+	QueryRawContext(ctx context.Context, query string, args ...interface{}) (*Rows, error)
+	QueryRawIter(query string, args ...interface{}) (*Cursor, error)
+	QueryRawIterContext(ctx context.Context, query string, args ...interface{}) (*Cursor, error)
+	Count(table string, cond *ConditionExpr) (int64, error)
+	CountContext(ctx context.Context, table string, cond *ConditionExpr) (int64, error)
+	Exists(table string, cond *ConditionExpr) (bool, error)
+	ExistsContext(ctx context.Context, table string, cond *ConditionExpr) (bool, error)
+	InsertMany(table string, rows []*ConditionExpr) (*BatchResult, error)
+	InsertManyContext(ctx context.Context, table string, rows []*ConditionExpr) (*BatchResult, error)
+	Upsert(table string, data *ConditionExpr, conflictColumns, updateColumns []string) (int64, error)
+	UpsertContext(ctx context.Context, table string, data *ConditionExpr, conflictColumns, updateColumns []string) (int64, error)
+	InsertReturning(table string, data *ConditionExpr, returning []string) (*Rows, error)
+	InsertReturningContext(ctx context.Context, table string, data *ConditionExpr, returning []string) (*Rows, error)
+	UpdateReturning(table string, where, set *ConditionExpr, returning []string) (*Rows, error)
+	UpdateReturningContext(ctx context.Context, table string, where, set *ConditionExpr, returning []string) (*Rows, error)
+	DeleteReturning(table string, cond *ConditionExpr, returning []string) (*Rows, error)
+	DeleteReturningContext(ctx context.Context, table string, cond *ConditionExpr, returning []string) (*Rows, error)
+
+	Inspector
 }
 
 type Tx interface {
 	Commit() error
 	Rollback() error
+	// Begin 创建保存点并返回嵌套事务，嵌套事务的 Commit/Rollback 只作用于该保存点
+	Begin() (Tx, error)
+	Savepoint(name string) error
+	RollbackTo(name string) error
+	Release(name string) error
 	Insert(table string, data *ConditionExpr) (int64, error)
-	Query(table string, cond *ConditionExpr) (*Rows, error)
-	Update(table string, data, cond *ConditionExpr) (int64, error)
+	Query(table string, cond *ConditionExpr, opts ...*QueryOptions) (*Rows, error)
+	Update(table string, where, set *ConditionExpr) (int64, error)
 	Delete(table string, cond *ConditionExpr) (int64, error)
 	Exec(cond *ConditionExpr) (int64, error)
+
+	InsertContext(ctx context.Context, table string, data *ConditionExpr) (int64, error)
+	QueryContext(ctx context.Context, table string, cond *ConditionExpr, opts ...*QueryOptions) (*Rows, error)
+	UpdateContext(ctx context.Context, table string, where, set *ConditionExpr) (int64, error)
+	DeleteContext(ctx context.Context, table string, cond *ConditionExpr) (int64, error)
+	ExecContext(ctx context.Context, cond *ConditionExpr) (int64, error)
+
+	QueryIter(table string, cond *ConditionExpr, opts ...*QueryOptions) (*Cursor, error)
+	QueryIterContext(ctx context.Context, table string, cond *ConditionExpr, opts ...*QueryOptions) (*Cursor, error)
+	QueryRaw(query string, args ...interface{}) (*Rows, error)
+	QueryRawContext(ctx context.Context, query string, args ...interface{}) (*Rows, error)
+	QueryRawIter(query string, args ...interface{}) (*Cursor, error)
+	QueryRawIterContext(ctx context.Context, query string, args ...interface{}) (*Cursor, error)
+	Count(table string, cond *ConditionExpr) (int64, error)
+	CountContext(ctx context.Context, table string, cond *ConditionExpr) (int64, error)
+	Exists(table string, cond *ConditionExpr) (bool, error)
+	ExistsContext(ctx context.Context, table string, cond *ConditionExpr) (bool, error)
+	InsertMany(table string, rows []*ConditionExpr) (*BatchResult, error)
+	InsertManyContext(ctx context.Context, table string, rows []*ConditionExpr) (*BatchResult, error)
+	Upsert(table string, data *ConditionExpr, conflictColumns, updateColumns []string) (int64, error)
+	UpsertContext(ctx context.Context, table string, data *ConditionExpr, conflictColumns, updateColumns []string) (int64, error)
+	InsertReturning(table string, data *ConditionExpr, returning []string) (*Rows, error)
+	InsertReturningContext(ctx context.Context, table string, data *ConditionExpr, returning []string) (*Rows, error)
+	UpdateReturning(table string, where, set *ConditionExpr, returning []string) (*Rows, error)
+	UpdateReturningContext(ctx context.Context, table string, where, set *ConditionExpr, returning []string) (*Rows, error)
+	DeleteReturning(table string, cond *ConditionExpr, returning []string) (*Rows, error)
+	DeleteReturningContext(ctx context.Context, table string, cond *ConditionExpr, returning []string) (*Rows, error)
+
+	Inspector
+}
+
+// Inspector 查询数据库结构，表名解析规则与各数据库默认一致（MySQL 为当前库，PostgreSQL 按 search_path）。
+// 表不存在时 Columns 返回 ErrNotFound，其余方法返回空结果
+type Inspector interface {
+	ListTables() ([]string, error)
+	ListTablesContext(ctx context.Context) ([]string, error)
+	Columns(table string) ([]ColumnInfo, error)
+	ColumnsContext(ctx context.Context, table string) ([]ColumnInfo, error)
+	Indexes(table string) ([]IndexInfo, error)
+	IndexesContext(ctx context.Context, table string) ([]IndexInfo, error)
+	// PrimaryKey 返回主键列，按主键中的顺序排列，没有主键时为空
+	PrimaryKey(table string) ([]string, error)
+	PrimaryKeyContext(ctx context.Context, table string) ([]string, error)
+	ForeignKeys(table string) ([]ForeignKeyInfo, error)
+	ForeignKeysContext(ctx context.Context, table string) ([]ForeignKeyInfo, error)
+}
+
+// RetryClassifier 由驱动实现，判断错误是否为重试整个事务即可解决的冲突（死锁、序列化失败等）
+type RetryClassifier interface {
+	IsRetryable(err error) bool
 }
 
 type Driver interface {
@@ -29,4 +123,12 @@ type Driver interface {
 type DSLParser interface {
 	Parse(op OpType, where *ConditionExpr, set *ConditionExpr) (string, []interface{}, error)
 	ParseAndCache(op OpType, where *ConditionExpr, set *ConditionExpr) (string, []interface{}, error)
+	ParseQuery(where *ConditionExpr, opts *QueryOptions) (string, []interface{}, error)
+	ParseQueryAndCache(where *ConditionExpr, opts *QueryOptions) (string, []interface{}, error)
+	ParseInsertMany(rows []*ConditionExpr) (string, []interface{}, error)
+	ParseInsertManyAndCache(rows []*ConditionExpr) (string, []interface{}, error)
+	ParseUpsert(data *ConditionExpr, opts *UpsertOptions) (string, []interface{}, error)
+	ParseUpsertAndCache(data *ConditionExpr, opts *UpsertOptions) (string, []interface{}, error)
+	ParseReturning(op OpType, where *ConditionExpr, set *ConditionExpr, returning []string) (string, []interface{}, error)
+	ParseReturningAndCache(op OpType, where *ConditionExpr, set *ConditionExpr, returning []string) (string, []interface{}, error)
 }
@@ -21,18 +21,36 @@ func (r *Rows) Get(col string) interface{} {
 
 // GetString 取字符串
 func (r *Rows) GetString(col string) string {
-	if v, ok := r.Get(col).([]byte); ok {
+	return toString(r.Get(col))
+}
+
+// GetInt 取整数
+func (r *Rows) GetInt(col string) int {
+	return toInt(r.Get(col))
+}
+
+// All 返回所有行
+func (r *Rows) All() []map[string]interface{} {
+	return r.data
+}
+
+// Count 返回行数
+func (r *Rows) Count() int {
+	return len(r.data)
+}
+
+func toString(val interface{}) string {
+	if v, ok := val.([]byte); ok {
 		return string(v)
 	}
-	if v, ok := r.Get(col).(string); ok {
+	if v, ok := val.(string); ok {
 		return v
 	}
 	return ""
 }
 
-// GetInt 取整数
-func (r *Rows) GetInt(col string) int {
-	switch v := r.Get(col).(type) {
+func toInt(val interface{}) int {
+	switch v := val.(type) {
 	case int:
 		return v
 	case int64:
@@ -43,13 +61,3 @@ func (r *Rows) GetInt(col string) int {
 	}
 	return 0
 }
-
-// All 返回所有行
-func (r *Rows) All() []map[string]interface{} {
-	return r.data
-}
-
-// Count 返回行数
-func (r *Rows) Count() int {
-	return len(r.data)
-}
@@ -0,0 +1,302 @@
+package types
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RowSource 可逐行读取的结果集，*Rows 与 *Cursor 均实现
+type RowSource interface {
+	Next() bool
+	current() ([]string, []interface{}, error)
+}
+
+func (r *Rows) current() ([]string, []interface{}, error) {
+	if r.pos < 0 || r.pos >= len(r.data) {
+		return nil, nil, fmt.Errorf("no current row, call Next first")
+	}
+	row := r.data[r.pos]
+	cols := make([]string, 0, len(row))
+	vals := make([]interface{}, 0, len(row))
+	for col, v := range row {
+		cols = append(cols, col)
+		vals = append(vals, v)
+	}
+	return cols, vals, nil
+}
+
+func (c *Cursor) current() ([]string, []interface{}, error) {
+	if !c.load() {
+		return nil, nil, c.Err()
+	}
+	return c.columns, c.row, nil
+}
+
+// ScanRow 将当前行扫描为 T。
+// T 为结构体（或其指针）时按 `db:"col"` 标签映射列，没有对应字段的列会被忽略；
+// T 为其他类型时结果集必须只有一列。
+func ScanRow[T any](src RowSource) (T, error) {
+	var out T
+	cols, vals, err := src.current()
+	if err != nil {
+		return out, err
+	}
+	err = scanInto(reflect.ValueOf(&out).Elem(), cols, vals)
+	return out, err
+}
+
+// ScanOne 读取下一行并扫描为 T，没有数据时返回 sql.ErrNoRows（即 ErrNotFound）
+func ScanOne[T any](src RowSource) (T, error) {
+	if !src.Next() {
+		var zero T
+		if err := sourceErr(src); err != nil {
+			return zero, err
+		}
+		return zero, sql.ErrNoRows
+	}
+	return ScanRow[T](src)
+}
+
+// ScanAll 读取剩余的所有行并扫描为 []T，不会关闭游标
+func ScanAll[T any](src RowSource) ([]T, error) {
+	var out []T
+	for src.Next() {
+		v, err := ScanRow[T](src)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := sourceErr(src); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func sourceErr(src RowSource) error {
+	if e, ok := src.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scanInto 将一行数据写入 dst
+func scanInto(dst reflect.Value, cols []string, vals []interface{}) error {
+	t := dst.Type()
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if base.Kind() != reflect.Struct || base == timeType || reflect.PointerTo(base).Implements(scannerType) {
+		if len(cols) != 1 {
+			return fmt.Errorf("cannot scan %d columns into %s, expected exactly one column", len(cols), t)
+		}
+		if err := setValue(dst, vals[0]); err != nil {
+			return fmt.Errorf("cannot scan column %s into %s: %w", cols[0], t, err)
+		}
+		return nil
+	}
+	for dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	info := getStructInfo(base)
+	for i, col := range cols {
+		fi, ok := info.byName[strings.ToLower(col)]
+		if !ok {
+			continue
+		}
+		if err := setValue(fieldByIndexAlloc(dst, fi.index), vals[i]); err != nil {
+			return fmt.Errorf("cannot scan column %s into field %s.%s (%s): %w", col, base.Name(), fi.field, fi.typ, err)
+		}
+	}
+	return nil
+}
+
+// setValue 将数据库驱动返回的值转换后写入字段
+func setValue(f reflect.Value, src interface{}) error {
+	if f.CanAddr() {
+		if s, ok := f.Addr().Interface().(sql.Scanner); ok {
+			return s.Scan(src)
+		}
+	}
+	if f.Kind() == reflect.Ptr {
+		if src == nil {
+			f.Set(reflect.Zero(f.Type()))
+			return nil
+		}
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		return setValue(f.Elem(), src)
+	}
+	if src == nil {
+		return fmt.Errorf("NULL value, use a pointer or sql.Null* type")
+	}
+	if f.Kind() == reflect.Interface {
+		f.Set(reflect.ValueOf(src))
+		return nil
+	}
+	if b, ok := src.([]byte); ok && f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8 {
+		f.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(f.Type()) {
+		f.Set(sv)
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case string:
+			f.SetString(v)
+		case []byte:
+			f.SetString(string(v))
+		case int64:
+			f.SetString(strconv.FormatInt(v, 10))
+		case float64:
+			f.SetString(strconv.FormatFloat(v, 'g', -1, 64))
+		case bool:
+			f.SetString(strconv.FormatBool(v))
+		case time.Time:
+			f.SetString(v.Format(time.RFC3339Nano))
+		default:
+			return fmt.Errorf("unsupported source type %T", src)
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := asInt64(src)
+		if err != nil {
+			return err
+		}
+		if f.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, f.Type())
+		}
+		f.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := asInt64(src)
+		if err != nil {
+			return err
+		}
+		if n < 0 || f.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %s", n, f.Type())
+		}
+		f.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, err := asFloat64(src)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+		return nil
+	case reflect.Bool:
+		switch v := src.(type) {
+		case int64:
+			f.SetBool(v != 0)
+			return nil
+		case []byte, string:
+			b, err := strconv.ParseBool(toString(v))
+			if err != nil {
+				return err
+			}
+			f.SetBool(b)
+			return nil
+		}
+	case reflect.Slice:
+		if f.Type().Elem().Kind() == reflect.Uint8 {
+			if v, ok := src.(string); ok {
+				f.SetBytes([]byte(v))
+				return nil
+			}
+		}
+	case reflect.Struct:
+		if f.Type() == timeType {
+			switch v := src.(type) {
+			case string, []byte:
+				tm, err := parseTime(toString(v))
+				if err != nil {
+					return err
+				}
+				f.Set(reflect.ValueOf(tm))
+				return nil
+			}
+		}
+	}
+	if sv.Type().ConvertibleTo(f.Type()) && sv.Kind() == f.Kind() {
+		f.Set(sv.Convert(f.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported source type %T", src)
+}
+
+func asInt64(src interface{}) (int64, error) {
+	switch v := src.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("value %v is not an integer", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte, string:
+		return strconv.ParseInt(toString(v), 10, 64)
+	}
+	return 0, fmt.Errorf("unsupported source type %T", src)
+}
+
+func asFloat64(src interface{}) (float64, error) {
+	switch v := src.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case []byte, string:
+		return strconv.ParseFloat(toString(v), 64)
+	}
+	return 0, fmt.Errorf("unsupported source type %T", src)
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", s)
+}
@@ -0,0 +1,34 @@
+package types
+
+// ColumnInfo 列结构
+type ColumnInfo struct {
+	Name string
+	// Type 数据库原生类型，如 VARCHAR(255)、integer
+	Type     string
+	Nullable bool
+	// Default 默认值表达式，没有默认值时为 nil
+	Default    *string
+	PrimaryKey bool
+	// AutoIncrement 值由数据库自动生成（AUTO_INCREMENT、SERIAL、IDENTITY、SQLite 的 INTEGER PRIMARY KEY）
+	AutoIncrement bool
+}
+
+// IndexInfo 索引结构，Columns 按索引中的顺序排列，表达式索引的表达式列为空串
+type IndexInfo struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+// ForeignKeyInfo 外键结构，Columns 与 RefColumns 一一对应
+type ForeignKeyInfo struct {
+	// Name 约束名，SQLite 不提供时为空
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	// OnUpdate、OnDelete 引用动作，如 NO ACTION、CASCADE、SET NULL
+	OnUpdate string
+	OnDelete string
+}
@@ -0,0 +1,143 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldInfo 结构体字段与数据库列的映射信息
+type fieldInfo struct {
+	name  string // 列名
+	field string // Go 字段名，嵌入结构体以 . 连接
+	index []int  // 字段路径，包含嵌入结构体
+	typ   reflect.Type
+
+	omitEmpty bool // 零值时不写入
+	readOnly  bool // 只读，从不写入
+	autoIncr  bool // 自增列，零值时不写入
+}
+
+// structInfo 结构体的列映射，fields 按声明顺序排列，byName 以小写列名索引
+type structInfo struct {
+	fields []*fieldInfo
+	byName map[string]*fieldInfo
+}
+
+var structInfoCache sync.Map // map[reflect.Type]*structInfo
+
+// getStructInfo 解析结构体的 db 标签并缓存结果。
+// 标签格式为 `db:"col,opt..."`，`db:"-"` 表示忽略；未设置标签时使用字段名；
+// 未设置标签的匿名嵌入结构体会被展开，外层字段优先。
+// 可选项：omitempty（零值不写入）、readonly（从不写入）、autoincr（自增列，零值不写入）。
+func getStructInfo(t reflect.Type) *structInfo {
+	if v, ok := structInfoCache.Load(t); ok {
+		return v.(*structInfo)
+	}
+	info := &structInfo{byName: make(map[string]*fieldInfo)}
+	collectFields(info, t, nil, "")
+	v, _ := structInfoCache.LoadOrStore(t, info)
+	return v.(*structInfo)
+}
+
+func collectFields(info *structInfo, t reflect.Type, parent []int, prefix string) {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("db")
+		name, opts := parseTag(tag)
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				// 未导出类型的嵌入指针无法通过反射分配，与 encoding/json 一样忽略
+				if !f.IsExported() {
+					continue
+				}
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		key := strings.ToLower(name)
+		if _, exists := info.byName[key]; exists {
+			continue
+		}
+		fi := &fieldInfo{
+			name:  name,
+			field: prefix + f.Name,
+			index: appendIndex(parent, f.Index...),
+			typ:   f.Type,
+		}
+		for _, opt := range opts {
+			switch strings.TrimSpace(opt) {
+			case "omitempty":
+				fi.omitEmpty = true
+			case "readonly":
+				fi.readOnly = true
+			case "autoincr", "autoincrement":
+				fi.autoIncr = true
+			}
+		}
+		info.fields = append(info.fields, fi)
+		info.byName[key] = fi
+	}
+	// 嵌入结构体的字段在外层字段之后处理，避免覆盖外层同名字段
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		collectFields(info, ft, appendIndex(parent, f.Index...), prefix+f.Name+".")
+	}
+}
+
+// parseTag 拆分标签为列名与选项
+func parseTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	return strings.TrimSpace(parts[0]), parts[1:]
+}
+
+func appendIndex(parent []int, index ...int) []int {
+	out := make([]int, 0, len(parent)+len(index))
+	out = append(out, parent...)
+	return append(out, index...)
+}
+
+// fieldByIndexAlloc 按路径取字段，途经的 nil 指针嵌入结构体会被分配
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldByIndexRead 按路径只读取字段，途经 nil 指针嵌入结构体时返回 false
+func fieldByIndexRead(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
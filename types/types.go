@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type ConditionOp string
 
 type ConditionExpr struct {
@@ -11,16 +13,236 @@ type ConditionExpr struct {
 }
 
 type DBConfig struct {
-	Driver  string
-	DSN     string
-	MaxOpen int
-	MaxIdle int
+	Driver          string
+	DSN             string
+	MaxOpen         int
+	MaxIdle         int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// ConnectTimeout Open 时检查连通性的超时时间，0 使用 DefaultConnectTimeout，负数不检查
+	ConnectTimeout time.Duration
 }
 
+// DefaultConnectTimeout Open 检查连通性的默认超时时间
+const DefaultConnectTimeout = 5 * time.Second
+
 // CondBuilder 用于构建通用条件表达式的结构体。
 type CondBuilder struct {
 	exprs []*ConditionExpr
 }
+
+// QueryOptions 查询选项：列投影、联表、聚合、分组、去重、排序与分页，零值表示 SELECT * 且不排序、不分页。
+// 列名可以写成 alias.column 的限定形式，限定列在结果中以 "alias.column" 为列名
+type QueryOptions struct {
+	// Alias 主表别名
+	Alias      string
+	Joins      []Join
+	Columns    []string
+	Aggregates []Aggregate
+	Distinct   bool
+	GroupBy    []string
+	// Having 分组过滤条件，字段名可以引用 Aggregates 的别名
+	Having  *ConditionExpr
+	OrderBy []OrderBy
+	Limit   int
+	Offset  int
+	// Lock 行锁模式，需在事务中使用；SQLite 不支持
+	Lock     LockMode
+	LockWait LockWait
+}
+
+type LockMode string
+
+const (
+	LockForUpdate LockMode = "FOR UPDATE"
+	LockForShare  LockMode = "FOR SHARE"
+)
+
+// LockWait 行已被锁定时的处理方式，默认等待
+type LockWait string
+
+const (
+	LockNoWait     LockWait = "NOWAIT"
+	LockSkipLocked LockWait = "SKIP LOCKED"
+)
+
+type JoinType string
+
+const (
+	JoinInner JoinType = "INNER JOIN"
+	JoinLeft  JoinType = "LEFT JOIN"
+	JoinRight JoinType = "RIGHT JOIN"
+	JoinCross JoinType = "CROSS JOIN"
+)
+
+// Join 联表，On 中字段之间的比较使用 Col 引用列
+type Join struct {
+	Type  JoinType
+	Table string
+	Alias string
+	On    *ConditionExpr
+}
+
+// InnerJoin 内连接
+func InnerJoin(table, alias string, on *ConditionExpr) Join {
+	return Join{Type: JoinInner, Table: table, Alias: alias, On: on}
+}
+
+// LeftJoin 左连接
+func LeftJoin(table, alias string, on *ConditionExpr) Join {
+	return Join{Type: JoinLeft, Table: table, Alias: alias, On: on}
+}
+
+// RightJoin 右连接，SQLite 3.39 起支持
+func RightJoin(table, alias string, on *ConditionExpr) Join {
+	return Join{Type: JoinRight, Table: table, Alias: alias, On: on}
+}
+
+// CrossJoin 交叉连接，没有 On 条件
+func CrossJoin(table, alias string) Join {
+	return Join{Type: JoinCross, Table: table, Alias: alias}
+}
+
+// Column 列引用，作为条件或更新数据的值时按列名渲染而不是绑定参数
+type Column string
+
+// Col 引用列，如 Col("u.id")
+func Col(name string) Column {
+	return Column(name)
+}
+
+// Add、Sub、Mul、Div 构建以该列为左操作数的算术表达式，如 Col("counter").Add(1)
+func (c Column) Add(v interface{}) *ArithExpr { return Arith(c, "+", v) }
+func (c Column) Sub(v interface{}) *ArithExpr { return Arith(c, "-", v) }
+func (c Column) Mul(v interface{}) *ArithExpr { return Arith(c, "*", v) }
+func (c Column) Div(v interface{}) *ArithExpr { return Arith(c, "/", v) }
+
+// ArithExpr 算术表达式，操作数可以是列引用、函数、其他表达式或普通值（绑定为参数）
+type ArithExpr struct {
+	Op    string
+	Left  interface{}
+	Right interface{}
+}
+
+// Arith 构建算术表达式，op 为 + - * / % 之一，如 Arith(Col("price"), "*", 0.8)
+func Arith(left interface{}, op string, right interface{}) *ArithExpr {
+	return &ArithExpr{Op: op, Left: left, Right: right}
+}
+
+// Add、Sub、Mul、Div 以当前表达式为左操作数继续构建
+func (e *ArithExpr) Add(v interface{}) *ArithExpr { return Arith(e, "+", v) }
+func (e *ArithExpr) Sub(v interface{}) *ArithExpr { return Arith(e, "-", v) }
+func (e *ArithExpr) Mul(v interface{}) *ArithExpr { return Arith(e, "*", v) }
+func (e *ArithExpr) Div(v interface{}) *ArithExpr { return Arith(e, "/", v) }
+
+// FuncExpr SQL 函数调用，参数规则与 ArithExpr 的操作数相同
+type FuncExpr struct {
+	Name string
+	Args []interface{}
+}
+
+// Fn 构建函数调用，如 Fn("NOW")、Fn("COALESCE", Col("nickname"), "anonymous")。
+// 函数名原样写入 SQL，只允许字母、数字与下划线
+func Fn(name string, args ...interface{}) *FuncExpr {
+	return &FuncExpr{Name: name, Args: args}
+}
+
+// SubQuery 子查询，可作为 In/NotIn 与比较条件的值，或用于 Exists/NotExists
+type SubQuery struct {
+	Table   string
+	Where   *ConditionExpr
+	Options *QueryOptions
+}
+
+// Sub 构建子查询，如 Sub("banned", nil, &QueryOptions{Columns: []string{"id"}})
+func Sub(table string, where *ConditionExpr, opts ...*QueryOptions) *SubQuery {
+	return &SubQuery{Table: table, Where: where, Options: PickQueryOptions(opts)}
+}
+
+type AggregateFunc string
+
+const (
+	AggCount AggregateFunc = "COUNT"
+	AggSum   AggregateFunc = "SUM"
+	AggAvg   AggregateFunc = "AVG"
+	AggMin   AggregateFunc = "MIN"
+	AggMax   AggregateFunc = "MAX"
+)
+
+// Aggregate 聚合列，Field 为空或 * 时仅 COUNT 可用
+type Aggregate struct {
+	Func     AggregateFunc
+	Field    string
+	Alias    string
+	Distinct bool
+}
+
+// Count COUNT 聚合，field 为 * 时统计行数
+func Count(field, alias string) Aggregate {
+	return Aggregate{Func: AggCount, Field: field, Alias: alias}
+}
+
+// Sum SUM 聚合
+func Sum(field, alias string) Aggregate {
+	return Aggregate{Func: AggSum, Field: field, Alias: alias}
+}
+
+// Avg AVG 聚合
+func Avg(field, alias string) Aggregate {
+	return Aggregate{Func: AggAvg, Field: field, Alias: alias}
+}
+
+// Min MIN 聚合
+func Min(field, alias string) Aggregate {
+	return Aggregate{Func: AggMin, Field: field, Alias: alias}
+}
+
+// Max MAX 聚合
+func Max(field, alias string) Aggregate {
+	return Aggregate{Func: AggMax, Field: field, Alias: alias}
+}
+
+// OrderBy 排序字段
+type OrderBy struct {
+	Field string
+	Desc  bool
+}
+
+// Asc 升序排序
+func Asc(field string) OrderBy {
+	return OrderBy{Field: field}
+}
+
+// Desc 降序排序
+func Desc(field string) OrderBy {
+	return OrderBy{Field: field, Desc: true}
+}
+
+// PickQueryOptions 返回可变参数中的第一个查询选项，没有时返回 nil
+func PickQueryOptions(opts []*QueryOptions) *QueryOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts[0]
+}
+
+// UpsertOptions 插入冲突时的处理方式
+type UpsertOptions struct {
+	// ConflictColumns 判断冲突的唯一键列；MySQL 由表上的唯一索引决定，仅在 DoNothing 时使用
+	ConflictColumns []string
+	// UpdateColumns 冲突时用新值覆盖的列，必须出现在插入数据中
+	UpdateColumns []string
+	// DoNothing 冲突时保留原有行
+	DoNothing bool
+}
+
+// BatchResult 批量插入结果
+type BatchResult struct {
+	RowsAffected int64
+	// InsertIDs 按插入顺序排列的自增 ID，方言无法提供时为 nil
+	InsertIDs []int64
+}
+
 type Rows struct {
 	data []map[string]interface{}
 	pos  int